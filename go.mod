@@ -5,9 +5,11 @@ go 1.23.8
 toolchain go1.24.2
 
 require (
+	github.com/pion/rtp v1.8.7
 	go.viam.com/rdk v0.78.0
 	go.viam.com/utils v0.1.143
 	gocv.io/x/gocv v0.40.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -100,7 +102,6 @@ require (
 	github.com/pion/mediadevices v0.6.4 // indirect
 	github.com/pion/randutil v0.1.0 // indirect
 	github.com/pion/rtcp v1.2.14 // indirect
-	github.com/pion/rtp v1.8.7 // indirect
 	github.com/pion/sctp v1.8.33 // indirect
 	github.com/pion/sdp/v3 v3.0.9 // indirect
 	github.com/pion/srtp/v2 v2.0.20 // indirect
@@ -163,6 +164,5 @@ require (
 	google.golang.org/grpc v1.71.0 // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 	nhooyr.io/websocket v1.8.7 // indirect
 )
@@ -4,11 +4,18 @@ import (
 	"video-replay/models"
 
 	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/components/sensor"
 	"go.viam.com/rdk/module"
 	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/generic"
 )
 
 func main() {
 	// ModularMain can take multiple APIModel arguments, if your module implements multiple models.
-	module.ModularMain(resource.APIModel{camera.API, models.Video})
+	module.ModularMain(
+		resource.APIModel{API: camera.API, Model: models.Video},
+		resource.APIModel{API: camera.API, Model: models.Thermal},
+		resource.APIModel{API: sensor.API, Model: models.ReadingsReplay},
+		resource.APIModel{API: generic.API, Model: models.ReplayClock},
+	)
 }
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go.viam.com/rdk/rimage/transform"
+	"gopkg.in/yaml.v3"
+)
+
+// calibration holds a replay source's camera calibration at the resolution it
+// was measured at
+type calibration struct {
+	intrinsics transform.PinholeCameraIntrinsics
+	distortion *transform.BrownConrady
+}
+
+// viamCalibrationFile is the Viam camera config layout for intrinsics and distortion
+type viamCalibrationFile struct {
+	IntrinsicParameters  *transform.PinholeCameraIntrinsics `json:"intrinsic_parameters"`
+	DistortionParameters *transform.BrownConrady            `json:"distortion_parameters"`
+}
+
+// openCVMatrix is an !!opencv-matrix node as written by cv::FileStorage
+type openCVMatrix struct {
+	Rows int       `yaml:"rows"`
+	Cols int       `yaml:"cols"`
+	Data []float64 `yaml:"data"`
+}
+
+// openCVCalibrationFile is the layout written by OpenCV's calibration samples
+type openCVCalibrationFile struct {
+	ImageWidth             int           `yaml:"image_width"`
+	ImageHeight            int           `yaml:"image_height"`
+	CameraMatrix           *openCVMatrix `yaml:"camera_matrix"`
+	DistortionCoefficients *openCVMatrix `yaml:"distortion_coefficients"`
+}
+
+// openCVYAMLHeader and openCVMatrixTag are OpenCV-isms a standard YAML parser rejects
+var (
+	openCVYAMLHeader = regexp.MustCompile(`(?m)^%YAML:?[0-9.]*\s*$`)
+	openCVMatrixTag  = regexp.MustCompile(`!!opencv-matrix`)
+)
+
+// loadCalibration reads a Viam intrinsics JSON file (.json) or an OpenCV
+// calibration YAML file (.yaml/.yml)
+func loadCalibration(path string) (*calibration, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read calibration file %q: %w", path, err)
+	}
+
+	var cal *calibration
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		cal, err = parseViamCalibration(raw)
+	case ".yaml", ".yml":
+		cal, err = parseOpenCVCalibration(raw)
+	default:
+		return nil, fmt.Errorf("calibration file %q must be .json (Viam) or .yaml/.yml (OpenCV)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid calibration file %q: %w", path, err)
+	}
+	// Width/height may be missing (the source resolution is assumed), so
+	// intrinsics.CheckValid is too strict here
+	if cal.intrinsics.Fx <= 0 || cal.intrinsics.Fy <= 0 {
+		return nil, fmt.Errorf("calibration file %q has invalid focal lengths fx=%v fy=%v",
+			path, cal.intrinsics.Fx, cal.intrinsics.Fy)
+	}
+	return cal, nil
+}
+
+// parseViamCalibration accepts either the full camera config layout or a bare
+// intrinsics object
+func parseViamCalibration(raw []byte) (*calibration, error) {
+	var file viamCalibrationFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, err
+	}
+	if file.IntrinsicParameters == nil {
+		var intrinsics transform.PinholeCameraIntrinsics
+		if err := json.Unmarshal(raw, &intrinsics); err != nil {
+			return nil, err
+		}
+		file.IntrinsicParameters = &intrinsics
+	}
+	return &calibration{intrinsics: *file.IntrinsicParameters, distortion: file.DistortionParameters}, nil
+}
+
+// parseOpenCVCalibration reads camera_matrix and distortion_coefficients.
+// OpenCV orders coefficients k1, k2, p1, p2, k3.
+func parseOpenCVCalibration(raw []byte) (*calibration, error) {
+	raw = openCVYAMLHeader.ReplaceAll(raw, nil)
+	raw = openCVMatrixTag.ReplaceAll(raw, nil)
+
+	var file openCVCalibrationFile
+	if err := yaml.Unmarshal(raw, &file); err != nil {
+		return nil, err
+	}
+	if file.CameraMatrix == nil || len(file.CameraMatrix.Data) != 9 {
+		return nil, fmt.Errorf("camera_matrix must be a 3x3 matrix")
+	}
+	k := file.CameraMatrix.Data
+	cal := &calibration{
+		intrinsics: transform.PinholeCameraIntrinsics{
+			Width:  file.ImageWidth,
+			Height: file.ImageHeight,
+			Fx:     k[0],
+			Fy:     k[4],
+			Ppx:    k[2],
+			Ppy:    k[5],
+		},
+	}
+
+	if d := file.DistortionCoefficients; d != nil && len(d.Data) > 0 {
+		coeffs := make([]float64, 5)
+		copy(coeffs, d.Data)
+		cal.distortion = &transform.BrownConrady{
+			RadialK1:     coeffs[0],
+			RadialK2:     coeffs[1],
+			TangentialP1: coeffs[2],
+			TangentialP2: coeffs[3],
+			RadialK3:     coeffs[4],
+		}
+	}
+	return cal, nil
+}
+
+// scaledIntrinsics maps the calibration onto the output frames, which are the
+// source (of size srcWidth x srcHeight) scaled and fit per cfg. A calibration
+// without a resolution is assumed to match the source.
+func (cal *calibration) scaledIntrinsics(cfg *Config, srcWidth, srcHeight int) *transform.PinholeCameraIntrinsics {
+	calWidth, calHeight := cal.intrinsics.Width, cal.intrinsics.Height
+	if calWidth <= 0 || calHeight <= 0 {
+		calWidth, calHeight = srcWidth, srcHeight
+	}
+	if calWidth <= 0 || calHeight <= 0 {
+		intrinsics := cal.intrinsics
+		return &intrinsics
+	}
+
+	l := cfg.layout(srcWidth, srcHeight)
+	scaleX := float64(l.scaledW) / float64(calWidth)
+	scaleY := float64(l.scaledH) / float64(calHeight)
+	return &transform.PinholeCameraIntrinsics{
+		Width:  l.width,
+		Height: l.height,
+		Fx:     cal.intrinsics.Fx * scaleX,
+		Fy:     cal.intrinsics.Fy * scaleY,
+		Ppx:    cal.intrinsics.Ppx*scaleX + float64(l.offsetX),
+		Ppy:    cal.intrinsics.Ppy*scaleY + float64(l.offsetY),
+	}
+}
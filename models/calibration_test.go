@@ -0,0 +1,192 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"go.viam.com/rdk/rimage/transform"
+)
+
+const openCVCalibrationYAML = `%YAML:1.0
+---
+image_width: 1280
+image_height: 720
+camera_matrix: !!opencv-matrix
+   rows: 3
+   cols: 3
+   dt: d
+   data: [ 900., 0., 640., 0., 910., 360., 0., 0., 1. ]
+distortion_coefficients: !!opencv-matrix
+   rows: 1
+   cols: 5
+   dt: d
+   data: [ 0.1, -0.2, 0.001, 0.002, 0.05 ]
+`
+
+func TestParseOpenCVCalibration(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		want       transform.PinholeCameraIntrinsics
+		distortion *transform.BrownConrady
+		wantErr    string
+	}{
+		{
+			name: "full file",
+			data: openCVCalibrationYAML,
+			want: transform.PinholeCameraIntrinsics{Width: 1280, Height: 720, Fx: 900, Fy: 910, Ppx: 640, Ppy: 360},
+			distortion: &transform.BrownConrady{
+				RadialK1: 0.1, RadialK2: -0.2, TangentialP1: 0.001, TangentialP2: 0.002, RadialK3: 0.05,
+			},
+		},
+		{
+			name:       "short distortion and no resolution",
+			data:       "camera_matrix:\n  rows: 3\n  cols: 3\n  data: [500, 0, 320, 0, 500, 240, 0, 0, 1]\ndistortion_coefficients:\n  data: [0.3, 0.1]\n",
+			want:       transform.PinholeCameraIntrinsics{Fx: 500, Fy: 500, Ppx: 320, Ppy: 240},
+			distortion: &transform.BrownConrady{RadialK1: 0.3, RadialK2: 0.1},
+		},
+		{
+			name: "no distortion",
+			data: "camera_matrix:\n  data: [500, 0, 320, 0, 500, 240, 0, 0, 1]\n",
+			want: transform.PinholeCameraIntrinsics{Fx: 500, Fy: 500, Ppx: 320, Ppy: 240},
+		},
+		{
+			name:    "missing camera matrix",
+			data:    "image_width: 640\n",
+			wantErr: "camera_matrix must be a 3x3 matrix",
+		},
+		{
+			name:    "wrong matrix size",
+			data:    "camera_matrix:\n  data: [500, 0, 320, 0, 500, 240]\n",
+			wantErr: "camera_matrix must be a 3x3 matrix",
+		},
+		{
+			name:    "not yaml",
+			data:    "camera_matrix: [\n",
+			wantErr: "yaml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cal, err := parseOpenCVCalibration([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cal.intrinsics != tt.want {
+				t.Errorf("intrinsics = %+v, want %+v", cal.intrinsics, tt.want)
+			}
+			switch {
+			case tt.distortion == nil && cal.distortion != nil:
+				t.Errorf("distortion = %+v, want none", *cal.distortion)
+			case tt.distortion != nil && (cal.distortion == nil || *cal.distortion != *tt.distortion):
+				t.Errorf("distortion = %+v, want %+v", cal.distortion, *tt.distortion)
+			}
+		})
+	}
+}
+
+func TestParseViamCalibration(t *testing.T) {
+	want := transform.PinholeCameraIntrinsics{Width: 640, Height: 480, Fx: 500, Fy: 510, Ppx: 320, Ppy: 240}
+	intrinsics := `{"width_px": 640, "height_px": 480, "fx": 500, "fy": 510, "ppx": 320, "ppy": 240}`
+	tests := []struct {
+		name           string
+		data           string
+		wantDistortion bool
+	}{
+		{name: "bare intrinsics", data: intrinsics},
+		{
+			name:           "camera config layout",
+			data:           `{"intrinsic_parameters": ` + intrinsics + `, "distortion_parameters": {"rk1": 0.1, "rk2": 0, "rk3": 0, "tp1": 0, "tp2": 0}}`,
+			wantDistortion: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cal, err := parseViamCalibration([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cal.intrinsics != want {
+				t.Errorf("intrinsics = %+v, want %+v", cal.intrinsics, want)
+			}
+			if (cal.distortion != nil) != tt.wantDistortion {
+				t.Errorf("distortion = %+v, want one: %v", cal.distortion, tt.wantDistortion)
+			}
+		})
+	}
+}
+
+func TestScaledIntrinsics(t *testing.T) {
+	size := func(v int) *int { return &v }
+	fit := func(v string) *string { return &v }
+	measured := &calibration{intrinsics: transform.PinholeCameraIntrinsics{
+		Width: 1280, Height: 720, Fx: 1000, Fy: 1000, Ppx: 640, Ppy: 360,
+	}}
+	unsized := &calibration{intrinsics: transform.PinholeCameraIntrinsics{Fx: 500, Fy: 500, Ppx: 320, Ppy: 240}}
+	tests := []struct {
+		name                string
+		cal                 *calibration
+		cfg                 Config
+		srcWidth, srcHeight int
+		want                transform.PinholeCameraIntrinsics
+	}{
+		{
+			name:     "unchanged",
+			cal:      measured,
+			srcWidth: 1280, srcHeight: 720,
+			want: transform.PinholeCameraIntrinsics{Width: 1280, Height: 720, Fx: 1000, Fy: 1000, Ppx: 640, Ppy: 360},
+		},
+		{
+			name:     "measured at a higher resolution than recorded",
+			cal:      measured,
+			srcWidth: 640, srcHeight: 360,
+			want: transform.PinholeCameraIntrinsics{Width: 640, Height: 360, Fx: 500, Fy: 500, Ppx: 320, Ppy: 180},
+		},
+		{
+			name:     "stretched",
+			cal:      measured,
+			cfg:      Config{Width: size(640), Height: size(720)},
+			srcWidth: 1280, srcHeight: 720,
+			want: transform.PinholeCameraIntrinsics{Width: 640, Height: 720, Fx: 500, Fy: 1000, Ppx: 320, Ppy: 360},
+		},
+		{
+			name:     "letterboxed",
+			cal:      measured,
+			cfg:      Config{Width: size(640), Height: size(640), Fit: fit(fitLetterbox)},
+			srcWidth: 1280, srcHeight: 720,
+			want: transform.PinholeCameraIntrinsics{Width: 640, Height: 640, Fx: 500, Fy: 500, Ppx: 320, Ppy: 320},
+		},
+		{
+			name:     "cropped",
+			cal:      measured,
+			cfg:      Config{Width: size(360), Height: size(360), Fit: fit(fitCrop)},
+			srcWidth: 1280, srcHeight: 720,
+			want: transform.PinholeCameraIntrinsics{Width: 360, Height: 360, Fx: 500, Fy: 500, Ppx: 180, Ppy: 180},
+		},
+		{
+			name:     "no resolution assumes the source",
+			cal:      unsized,
+			cfg:      Config{Width: size(320)},
+			srcWidth: 640, srcHeight: 480,
+			want: transform.PinholeCameraIntrinsics{Width: 320, Height: 240, Fx: 250, Fy: 250, Ppx: 160, Ppy: 120},
+		},
+		{
+			name: "nothing to scale by",
+			cal:  unsized,
+			want: transform.PinholeCameraIntrinsics{Fx: 500, Fy: 500, Ppx: 320, Ppy: 240},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cal.scaledIntrinsics(&tt.cfg, tt.srcWidth, tt.srcHeight); *got != tt.want {
+				t.Errorf("scaledIntrinsics = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
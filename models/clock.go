@@ -0,0 +1,230 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/resource"
+)
+
+// syncMember is a replay source that can follow a playback clock
+type syncMember interface {
+	Name() resource.Name
+}
+
+// playbackClock is the media clock shared by the sources of a sync_group. Its
+// position is the time elapsed in the recording since the group's earliest
+// source started; it advances at speed while playing. A source outside any
+// group can follow a clock of its own, with an empty name.
+type playbackClock struct {
+	name string
+
+	mu         sync.Mutex
+	anchorWall time.Time     // wall time position was last set
+	anchorPos  time.Duration // position at anchorWall
+	speed      float64
+	paused     bool
+	members    map[syncMember]time.Time // member => capture time its source starts at; zero if unknown
+}
+
+// syncGroups holds the clocks of the active sync groups by name
+var syncGroups = struct {
+	sync.Mutex
+	clocks map[string]*playbackClock
+}{clocks: map[string]*playbackClock{}}
+
+// lookupSyncGroup returns the clock of the named group, if any camera is in it
+func lookupSyncGroup(name string) (*playbackClock, bool) {
+	syncGroups.Lock()
+	defer syncGroups.Unlock()
+	clock, ok := syncGroups.clocks[name]
+	return clock, ok
+}
+
+// newPlaybackClock returns a clock playing from the start. Only clocks created
+// through joinSyncGroup are shared.
+func newPlaybackClock(name string) *playbackClock {
+	return &playbackClock{
+		name:       name,
+		anchorWall: time.Now(),
+		speed:      1,
+		members:    map[syncMember]time.Time{},
+	}
+}
+
+// joinSyncGroup adds member to the named group, creating the group's clock,
+// playing from the start, if member is its first
+func joinSyncGroup(name string, member syncMember) *playbackClock {
+	syncGroups.Lock()
+	defer syncGroups.Unlock()
+	clock, ok := syncGroups.clocks[name]
+	if !ok {
+		clock = newPlaybackClock(name)
+		syncGroups.clocks[name] = clock
+	}
+	clock.join(member)
+	return clock
+}
+
+// join adds member to the clock with an unknown source start
+func (c *playbackClock) join(member syncMember) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.members[member] = time.Time{}
+}
+
+// leave removes member from the group; the group ends with its last member
+func (c *playbackClock) leave(member syncMember) {
+	syncGroups.Lock()
+	defer syncGroups.Unlock()
+	c.mu.Lock()
+	delete(c.members, member)
+	empty := len(c.members) == 0
+	c.mu.Unlock()
+	if empty && syncGroups.clocks[c.name] == c {
+		delete(syncGroups.clocks, c.name)
+	}
+}
+
+// setSourceStart records the capture time member's source starts at, used to
+// align it with the rest of the group. Zero means unknown: the source is
+// aligned with the start of the group.
+func (c *playbackClock) setSourceStart(member syncMember, start time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.members[member]; ok {
+		c.members[member] = start
+	}
+}
+
+// origin returns the earliest known source start in the group. Callers must hold mu.
+func (c *playbackClock) origin() time.Time {
+	var origin time.Time
+	for _, start := range c.members {
+		if !start.IsZero() && (origin.IsZero() || start.Before(origin)) {
+			origin = start
+		}
+	}
+	return origin
+}
+
+// positionLocked returns the clock position. Callers must hold mu.
+func (c *playbackClock) positionLocked() time.Duration {
+	if c.paused {
+		return c.anchorPos
+	}
+	return c.anchorPos + time.Duration(float64(time.Since(c.anchorWall))*c.speed)
+}
+
+// sourcePosition returns the clock position in member's own timeline: the
+// time since its source started. It is negative before the source starts.
+func (c *playbackClock) sourcePosition(member syncMember) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pos := c.positionLocked()
+	if start := c.members[member]; !start.IsZero() {
+		pos -= start.Sub(c.origin())
+	}
+	return pos
+}
+
+// reanchor sets the clock position from now on. Callers must hold mu.
+func (c *playbackClock) reanchor(pos time.Duration) {
+	c.anchorPos = max(0, pos)
+	c.anchorWall = time.Now()
+}
+
+// play resumes the clock
+func (c *playbackClock) play() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused {
+		c.reanchor(c.anchorPos)
+		c.paused = false
+	}
+}
+
+// pause freezes the clock at its current position
+func (c *playbackClock) pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.paused {
+		c.reanchor(c.positionLocked())
+		c.paused = true
+	}
+}
+
+// seek moves the clock to pos, keeping it playing or paused
+func (c *playbackClock) seek(pos time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reanchor(pos)
+}
+
+// setSpeed changes the playback rate, e.g. 2 for double speed
+func (c *playbackClock) setSpeed(speed float64) error {
+	if speed <= 0 {
+		return fmt.Errorf("speed must be positive, got %v", speed)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reanchor(c.positionLocked())
+	c.speed = speed
+	return nil
+}
+
+// status reports the clock state for DoCommand
+func (c *playbackClock) status() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	members := make([]string, 0, len(c.members))
+	for member := range c.members {
+		members = append(members, member.Name().String())
+	}
+	sort.Strings(members)
+	status := map[string]interface{}{
+		"position_ms": float64(c.positionLocked()) / float64(time.Millisecond),
+		"speed":       c.speed,
+		"paused":      c.paused,
+		"members":     members,
+	}
+	if c.name != "" {
+		status["sync_group"] = c.name
+	}
+	if origin := c.origin(); !origin.IsZero() {
+		status["origin"] = origin.UTC().Format(time.RFC3339Nano)
+		status["replay_time"] = origin.Add(c.positionLocked()).UTC().Format(time.RFC3339Nano)
+	}
+	return status
+}
+
+// command handles the clock DoCommands: "play", "pause", "seek"
+// (position_ms), "set_speed" (speed) and "clock". Each returns the clock status.
+func (c *playbackClock) command(name string, cmd map[string]interface{}) (map[string]interface{}, error) {
+	switch name {
+	case "play":
+		c.play()
+	case "pause":
+		c.pause()
+	case "seek":
+		ms, ok := numberValue(cmd["position_ms"])
+		if !ok {
+			return nil, fmt.Errorf("seek requires a numeric position_ms")
+		}
+		c.seek(time.Duration(ms * float64(time.Millisecond)))
+	case "set_speed":
+		speed, ok := numberValue(cmd["speed"])
+		if !ok {
+			return nil, fmt.Errorf("set_speed requires a numeric speed")
+		}
+		if err := c.setSpeed(speed); err != nil {
+			return nil, err
+		}
+	case "clock":
+	default:
+		return nil, fmt.Errorf("unknown command %q", name)
+	}
+	return c.status(), nil
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/resource"
+)
+
+// testMember is a sync group member known only by name
+type testMember string
+
+func (m testMember) Name() resource.Name {
+	return resource.NewName(camera.API, string(m))
+}
+
+// pausedClock returns a paused clock at pos, so positions don't move under the test
+func pausedClock(pos time.Duration) *playbackClock {
+	c := newPlaybackClock("")
+	c.pause()
+	c.seek(pos)
+	return c
+}
+
+func TestPlaybackClockCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		command   string
+		cmd       map[string]interface{}
+		wantPos   time.Duration
+		wantSpeed float64
+		wantErr   string
+	}{
+		{name: "clock", command: "clock", wantPos: 5 * time.Second, wantSpeed: 1},
+		{name: "seek", command: "seek", cmd: map[string]interface{}{"position_ms": 1500.0}, wantPos: 1500 * time.Millisecond, wantSpeed: 1},
+		{name: "seek int", command: "seek", cmd: map[string]interface{}{"position_ms": 2000}, wantPos: 2 * time.Second, wantSpeed: 1},
+		{name: "seek before the start", command: "seek", cmd: map[string]interface{}{"position_ms": -10.0}, wantPos: 0, wantSpeed: 1},
+		{name: "seek without position", command: "seek", cmd: map[string]interface{}{}, wantErr: "numeric position_ms"},
+		{name: "seek with a string", command: "seek", cmd: map[string]interface{}{"position_ms": "10"}, wantErr: "numeric position_ms"},
+		{name: "set_speed", command: "set_speed", cmd: map[string]interface{}{"speed": 2.5}, wantPos: 5 * time.Second, wantSpeed: 2.5},
+		{name: "set_speed int", command: "set_speed", cmd: map[string]interface{}{"speed": int64(3)}, wantPos: 5 * time.Second, wantSpeed: 3},
+		{name: "set_speed zero", command: "set_speed", cmd: map[string]interface{}{"speed": 0.0}, wantErr: "speed must be positive"},
+		{name: "set_speed missing", command: "set_speed", cmd: map[string]interface{}{}, wantErr: "numeric speed"},
+		{name: "unknown", command: "rewind", wantErr: `unknown command "rewind"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := pausedClock(5 * time.Second)
+			status, err := c.command(tt.command, tt.cmd)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			wantMs := float64(tt.wantPos) / float64(time.Millisecond)
+			if status["position_ms"] != wantMs || status["speed"] != tt.wantSpeed || status["paused"] != true {
+				t.Errorf("status = %v, want position_ms %v, speed %v, paused", status, wantMs, tt.wantSpeed)
+			}
+		})
+	}
+}
+
+func TestPlaybackClockPlayPause(t *testing.T) {
+	c := newPlaybackClock("")
+	c.pause()
+	paused := c.sourcePosition(nil)
+	time.Sleep(20 * time.Millisecond)
+	if pos := c.sourcePosition(nil); pos != paused {
+		t.Fatalf("paused clock moved from %v to %v", paused, pos)
+	}
+
+	c.seek(time.Second)
+	if err := c.setSpeed(4); err != nil {
+		t.Fatal(err)
+	}
+	c.play()
+	time.Sleep(20 * time.Millisecond)
+	c.pause()
+	// 20ms of wall time at 4x; allow for a slow scheduler
+	if pos := c.sourcePosition(nil); pos < time.Second+80*time.Millisecond || pos > 2*time.Second {
+		t.Errorf("clock at %v after 20ms at 4x from 1s", pos)
+	}
+}
+
+func TestPlaybackClockSourcePosition(t *testing.T) {
+	origin := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		starts map[testMember]time.Time
+		member testMember
+		want   time.Duration
+	}{
+		{
+			name:   "earliest source",
+			starts: map[testMember]time.Time{"a": origin, "b": origin.Add(3 * time.Second)},
+			member: "a",
+			want:   10 * time.Second,
+		},
+		{
+			name:   "later source",
+			starts: map[testMember]time.Time{"a": origin, "b": origin.Add(3 * time.Second)},
+			member: "b",
+			want:   7 * time.Second,
+		},
+		{
+			name:   "source not started yet",
+			starts: map[testMember]time.Time{"a": origin, "b": origin.Add(12 * time.Second)},
+			member: "b",
+			want:   -2 * time.Second,
+		},
+		{
+			name:   "unknown start follows the group",
+			starts: map[testMember]time.Time{"a": origin.Add(time.Minute), "b": {}},
+			member: "b",
+			want:   10 * time.Second,
+		},
+		{
+			name:   "not a member",
+			starts: map[testMember]time.Time{"a": origin.Add(time.Minute)},
+			member: "c",
+			want:   10 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := pausedClock(10 * time.Second)
+			for member, start := range tt.starts {
+				c.join(member)
+				c.setSourceStart(member, start)
+			}
+			if got := c.sourcePosition(tt.member); got != tt.want {
+				t.Errorf("sourcePosition(%q) = %v, want %v", tt.member, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncGroupMembership(t *testing.T) {
+	a, b := testMember("a"), testMember("b")
+	clock := joinSyncGroup("test-group", a)
+	if joinSyncGroup("test-group", b) != clock {
+		t.Fatal("members of one group got different clocks")
+	}
+	clock.leave(a)
+	if got, ok := lookupSyncGroup("test-group"); !ok || got != clock {
+		t.Fatal("group ended while it still had a member")
+	}
+	clock.leave(b)
+	if _, ok := lookupSyncGroup("test-group"); ok {
+		t.Fatal("group outlived its last member")
+	}
+}
@@ -0,0 +1,233 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.viam.com/rdk/utils"
+)
+
+// mjpegBoundary separates the parts of the MJPEG stream
+const mjpegBoundary = "videoreplayframe"
+
+// debugIndexPage is served at / and shows the stream with a link to the status
+const debugIndexPage = `<!DOCTYPE html>
+<html>
+<head><title>%[1]s</title></head>
+<body style="margin:0;background:#222;color:#ddd;font-family:sans-serif">
+<p style="margin:8px">%[1]s &middot; <a style="color:#9cf" href="/status">status</a> &middot; <a style="color:#9cf" href="/frame.jpg">frame.jpg</a></p>
+<img src="/stream.mjpeg" style="max-width:100%%">
+</body>
+</html>
+`
+
+// debugShutdownTimeout bounds how long stopping the debug server waits for
+// in-flight requests
+const debugShutdownTimeout = 5 * time.Second
+
+// debugView is the configuration the debug handlers use, fixed when the
+// server starts so they never read fields Reconfigure rewrites
+type debugView struct {
+	mode    string
+	encode  encodeOptions
+	dataset *DatasetReplay
+}
+
+// startDebugServer serves the replay over HTTP on localhost when
+// debug_http_port is set:
+//   - /: a page showing the stream
+//   - /stream.mjpeg: every new frame as a Motion-JPEG stream
+//   - /frame.jpg: the current frame
+//   - /status: JSON playback status
+func (s *videoReplayVideo) startDebugServer() error {
+	if s.cfg.DebugHTTPPort == nil {
+		return nil
+	}
+
+	view := debugView{mode: s.mode, encode: s.cfg.encodeOptions()}
+	if s.mode == "dataset" || s.mode == "capture_history" {
+		view.dataset = s.datasetReplay
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.serveDebugIndex)
+	mux.HandleFunc("/stream.mjpeg", func(w http.ResponseWriter, r *http.Request) { s.serveMJPEG(w, r, view) })
+	mux.HandleFunc("/frame.jpg", func(w http.ResponseWriter, r *http.Request) { s.serveFrameJPEG(w, r, view) })
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) { s.serveStatus(w, r, view) })
+
+	addr := net.JoinHostPort("localhost", strconv.Itoa(*s.cfg.DebugHTTPPort))
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to start debug HTTP server on %s: %w", addr, err)
+	}
+	// Request contexts end when the server stops, so open streams finish
+	ctx, cancel := context.WithCancel(s.mainCtx)
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	s.debugServer = server
+	s.debugCancel = cancel
+	s.logger.Infof("[startDebugServer] Serving %q at http://%s/", s.name, addr)
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Errorf("[startDebugServer] Debug HTTP server for %q stopped: %v", s.name, err)
+		}
+	}()
+	return nil
+}
+
+// stopDebugServer ends open streams and shuts the debug HTTP server down,
+// waiting for in-flight requests to finish
+func (s *videoReplayVideo) stopDebugServer() {
+	if s.debugServer == nil {
+		return
+	}
+	s.debugCancel()
+	ctx, cancel := context.WithTimeout(context.Background(), debugShutdownTimeout)
+	defer cancel()
+	if err := s.debugServer.Shutdown(ctx); err != nil {
+		s.logger.Debugf("[stopDebugServer] Failed to shut down debug HTTP server for %q: %v", s.name, err)
+		s.debugServer.Close()
+	}
+	s.debugServer = nil
+	s.debugCancel = nil
+}
+
+// serveDebugIndex serves the viewer page
+func (s *videoReplayVideo) serveDebugIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, debugIndexPage, html.EscapeString(s.name.String()))
+}
+
+// serveFrameJPEG serves the current frame as a JPEG
+func (s *videoReplayVideo) serveFrameJPEG(w http.ResponseWriter, r *http.Request, view debugView) {
+	frame, err := s.acquireFrame()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer frame.release()
+
+	data, err := frame.Encode(r.Context(), utils.MimeTypeJPEG, view.encode)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", utils.MimeTypeJPEG)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
+
+// serveMJPEG streams every new frame as a part of a multipart response until
+// the client goes away or the server shuts down. A slow client skips to the
+// latest frame.
+func (s *videoReplayVideo) serveMJPEG(w http.ResponseWriter, r *http.Request, view debugView) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	sub := s.subscribeFrames(1, dropOldest)
+	defer s.frames.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+mjpegBoundary)
+	w.Header().Set("Cache-Control", "no-store")
+	ctx := r.Context()
+	for {
+		var ev frameEvent
+		select {
+		case <-ctx.Done():
+			return
+		case next, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			ev = next
+		}
+		if ev.err != nil {
+			continue
+		}
+
+		data, err := ev.frame.Encode(ctx, utils.MimeTypeJPEG, view.encode)
+		ev.release()
+		if err != nil {
+			s.logger.Debugf("[serveMJPEG] Failed to encode frame for %q: %v", s.name, err)
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "--%s\r\nContent-Type: %s\r\nContent-Length: %d\r\n\r\n",
+			mjpegBoundary, utils.MimeTypeJPEG, len(data)); err != nil {
+			return
+		}
+		if _, err := w.Write(data); err != nil {
+			return
+		}
+		if _, err := w.Write([]byte("\r\n")); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// serveStatus reports playback state as JSON
+func (s *videoReplayVideo) serveStatus(w http.ResponseWriter, r *http.Request, view debugView) {
+	status := map[string]interface{}{
+		"name": s.name.String(),
+		"mode": view.mode,
+	}
+
+	s.frameMutex.RLock()
+	if s.current != nil {
+		status["width"] = s.current.width
+		status["height"] = s.current.height
+		status["source_width"] = s.sourceWidth
+		status["source_height"] = s.sourceHeight
+		status["frame"] = s.current.meta.provenance()
+	}
+	if s.frameErr != nil {
+		status["frame_error"] = s.frameErr.Error()
+	}
+	s.frameMutex.RUnlock()
+
+	status["dropped_frames"] = s.droppedFrames.Load()
+	if stats := s.faultStats(); stats != nil {
+		status["faults"] = stats
+	}
+	if stats := s.imageFaultStats(); stats != nil {
+		status["image_faults"] = stats
+	}
+	if stats := s.degradationStats(); stats != nil {
+		status["degradations"] = stats
+	}
+
+	s.frames.mu.Lock()
+	status["frame_subscribers"] = len(s.frames.subs)
+	s.frames.mu.Unlock()
+
+	s.rtpMu.Lock()
+	status["rtp_subscriptions"] = len(s.rtpSubs)
+	s.rtpMu.Unlock()
+
+	if view.dataset != nil {
+		status["decode_errors"] = view.dataset.decodeErrorReport()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(status); err != nil {
+		s.logger.Debugf("[serveStatus] Failed to write status for %q: %v", s.name, err)
+	}
+}
@@ -0,0 +1,507 @@
+package models
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// noiseTileSize is the side of the seeded noise tile that is repeated to
+// cover a frame; each frame samples it at a random offset
+const noiseTileSize = 256
+
+// hazeColor is the gray level steam fades the image towards
+const hazeColor = 235
+
+// hazeRiseSec is how long steam takes to rise through the whole frame
+const hazeRiseSec = 10
+
+// DegradationConfig degrades decoded frames the way a dirty, steamy kitchen
+// camera would, to test how vision models hold up on clean recordings. Each
+// effect has its own intensity schedule; all randomness comes from seed.
+type DegradationConfig struct {
+	Occlusion   *Degradation `json:"occlusion,omitempty"`    // a dark blob in front of the lens; amount = fraction of the frame covered (default 0.3)
+	Haze        *Degradation `json:"haze,omitempty"`         // rising steam; amount = densest haze opacity, 0-1 (default 0.7)
+	Smudges     *Degradation `json:"smudges,omitempty"`      // greasy lens smudges; amount = their opacity, 0-1 (default 0.8)
+	DefocusBlur *Degradation `json:"defocus_blur,omitempty"` // amount = blur radius in pixels (default 10)
+	MotionBlur  *Degradation `json:"motion_blur,omitempty"`  // amount = blur length in pixels (default 25)
+	Exposure    *Degradation `json:"exposure,omitempty"`     // amount = exposure shift in stops, negative to darken (default 1.5)
+	Noise       *Degradation `json:"noise,omitempty"`        // Gaussian sensor noise; amount = standard deviation in pixel levels (default 20)
+	JPEG        *Degradation `json:"jpeg,omitempty"`         // recompression; amount = JPEG quality at full intensity, 1-100 (default 5)
+	Seed        *int64       `json:"seed,omitempty"`         // random seed; a time-based seed is used, and logged, when unset
+}
+
+// Degradation is one effect: how strong it is at full intensity, and how
+// its intensity changes over recording time
+type Degradation struct {
+	Amount    *float64       `json:"amount,omitempty"`     // strength at full intensity; its meaning depends on the effect
+	Intensity *float64       `json:"intensity,omitempty"`  // constant intensity, 0-1, when there's no schedule (default 1)
+	Schedule  []IntensityKey `json:"schedule,omitempty"`   // intensity keyframes, interpolated linearly
+	RepeatSec *float64       `json:"repeat_sec,omitempty"` // repeat the schedule with this period
+	AngleDeg  *float64       `json:"angle_deg,omitempty"`  // motion_blur direction, 0 for horizontal (default 0)
+	Count     *int           `json:"count,omitempty"`      // number of smudges (default 4)
+}
+
+// IntensityKey is an effect's intensity at a point of recording time
+type IntensityKey struct {
+	AtSec     float64 `json:"at_sec"`
+	Intensity float64 `json:"intensity"`
+}
+
+// namedDegradation pairs an effect with its config name
+type namedDegradation struct {
+	name string
+	*Degradation
+}
+
+// effects returns the configured effects in the order they are applied:
+// things in front of the lens, then the lens, then the sensor, then encoding
+func (c *DegradationConfig) effects() []namedDegradation {
+	var effects []namedDegradation
+	for _, e := range []namedDegradation{
+		{"occlusion", c.Occlusion},
+		{"haze", c.Haze},
+		{"smudges", c.Smudges},
+		{"defocus_blur", c.DefocusBlur},
+		{"motion_blur", c.MotionBlur},
+		{"exposure", c.Exposure},
+		{"noise", c.Noise},
+		{"jpeg", c.JPEG},
+	} {
+		if e.Degradation != nil {
+			effects = append(effects, e)
+		}
+	}
+	return effects
+}
+
+// defaultDegradationAmounts are the full-intensity strengths of effects
+// without an amount
+var defaultDegradationAmounts = map[string]float64{
+	"occlusion":    0.3,
+	"haze":         0.7,
+	"smudges":      0.8,
+	"defocus_blur": 10,
+	"motion_blur":  25,
+	"exposure":     1.5,
+	"noise":        20,
+	"jpeg":         5,
+}
+
+// validate checks every configured effect
+func (c *DegradationConfig) validate() error {
+	effects := c.effects()
+	if len(effects) == 0 {
+		return fmt.Errorf("degradations needs at least one effect")
+	}
+	for _, e := range effects {
+		if err := e.validate(); err != nil {
+			return fmt.Errorf("degradations.%s: %w", e.name, err)
+		}
+	}
+	return nil
+}
+
+// validate checks the effect's schedule and amount
+func (e namedDegradation) validate() error {
+	if e.Intensity != nil && (*e.Intensity < 0 || *e.Intensity > 1) {
+		return fmt.Errorf("intensity must be between 0 and 1, got %v", *e.Intensity)
+	}
+	for i, key := range e.Schedule {
+		if key.Intensity < 0 || key.Intensity > 1 {
+			return fmt.Errorf("schedule[%d].intensity must be between 0 and 1, got %v", i, key.Intensity)
+		}
+		if key.AtSec < 0 || (i > 0 && key.AtSec <= e.Schedule[i-1].AtSec) {
+			return fmt.Errorf("schedule[%d].at_sec must be at least 0 and after the previous key", i)
+		}
+	}
+	if e.RepeatSec != nil && *e.RepeatSec <= 0 {
+		return fmt.Errorf("repeat_sec must be positive, got %v", *e.RepeatSec)
+	}
+	if e.Count != nil && *e.Count < 1 {
+		return fmt.Errorf("count must be at least 1, got %d", *e.Count)
+	}
+	if e.Amount == nil {
+		return nil
+	}
+	amount := *e.Amount
+	switch e.name {
+	case "occlusion", "haze", "smudges":
+		if amount < 0 || amount > 1 {
+			return fmt.Errorf("amount must be between 0 and 1, got %v", amount)
+		}
+	case "jpeg":
+		if amount < 1 || amount > 100 {
+			return fmt.Errorf("amount must be a quality between 1 and 100, got %v", amount)
+		}
+	case "exposure":
+	default:
+		if amount < 0 {
+			return fmt.Errorf("amount must not be negative, got %v", amount)
+		}
+	}
+	return nil
+}
+
+// amount returns the effect's strength at full intensity
+func (e namedDegradation) amount() float64 {
+	if e.Amount != nil {
+		return *e.Amount
+	}
+	return defaultDegradationAmounts[e.name]
+}
+
+// level returns the effect's intensity at t into the recording
+func (e namedDegradation) level(t time.Duration) float64 {
+	if len(e.Schedule) == 0 {
+		if e.Intensity != nil {
+			return *e.Intensity
+		}
+		return 1
+	}
+	sec := max(0, t.Seconds())
+	if e.RepeatSec != nil {
+		sec = math.Mod(sec, *e.RepeatSec)
+	}
+	keys := e.Schedule
+	if sec <= keys[0].AtSec {
+		return keys[0].Intensity
+	}
+	for i := 1; i < len(keys); i++ {
+		if sec <= keys[i].AtSec {
+			frac := (sec - keys[i-1].AtSec) / (keys[i].AtSec - keys[i-1].AtSec)
+			return keys[i-1].Intensity + frac*(keys[i].Intensity-keys[i-1].Intensity)
+		}
+	}
+	return keys[len(keys)-1].Intensity
+}
+
+// degrader applies the configured degradations to frames. Its random layers
+// (noise, steam, smudges, the occluder) are drawn from the seed for each frame
+// size, so a run with the same seed degrades frames the same way. A nil
+// degrader leaves frames untouched.
+type degrader struct {
+	cfg  *DegradationConfig
+	seed int64
+
+	mu       sync.Mutex
+	rng      *rand.Rand
+	closed   bool
+	size     image.Point
+	noise    gocv.Mat // unit Gaussian noise, a noise tile larger than the frame
+	haze     gocv.Mat // steam density 0-1, twice the frame height so it can rise and wrap
+	smudges  gocv.Mat // smudge opacity 0-1
+	occluder occluder
+	frames   uint64
+	levels   map[string]float64
+}
+
+// occluder is the shape of the blob covering part of the frame
+type occluder struct {
+	center image.Point
+	aspect float64 // height over width
+	angle  float64
+}
+
+// newDegrader returns a degrader for cfg, or nil when degradations aren't configured
+func newDegrader(cfg *DegradationConfig) *degrader {
+	if cfg == nil {
+		return nil
+	}
+	seed := time.Now().UnixNano()
+	if cfg.Seed != nil {
+		seed = *cfg.Seed
+	}
+	return &degrader{cfg: cfg, seed: seed, rng: rand.New(rand.NewSource(seed))}
+}
+
+// apply degrades an 8-bit BGR frame t into the recording, taking ownership of
+// frame. Other frames, such as depth, are returned untouched.
+func (d *degrader) apply(frame gocv.Mat, t time.Duration) gocv.Mat {
+	if d == nil || frame.Type() != gocv.MatTypeCV8UC3 {
+		return frame
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return frame
+	}
+	if err := d.prepare(frame.Cols(), frame.Rows()); err != nil {
+		return frame
+	}
+
+	d.frames++
+	d.levels = make(map[string]float64)
+	var f gocv.Mat
+	converted := false
+	quality := 100
+	for _, e := range d.cfg.effects() {
+		level := e.level(t)
+		d.levels[e.name] = level
+		if level <= 0 {
+			continue
+		}
+		if e.name == "jpeg" {
+			quality = int(math.Round(100 - level*(100-e.amount())))
+			continue
+		}
+		if !converted {
+			f, converted = gocv.NewMat(), true
+			frame.ConvertTo(&f, gocv.MatTypeCV32FC3)
+		}
+		d.applyEffect(&f, e, level*e.amount(), t)
+	}
+	if converted {
+		f.ConvertTo(&frame, gocv.MatTypeCV8UC3)
+		f.Close()
+	}
+	if quality < 100 {
+		frame = recompress(frame, quality)
+	}
+	return frame
+}
+
+// applyEffect applies one effect at the given strength to a float frame
+func (d *degrader) applyEffect(f *gocv.Mat, e namedDegradation, strength float64, t time.Duration) {
+	switch e.name {
+	case "occlusion":
+		d.occlude(f, strength)
+	case "haze":
+		offset := int(t.Seconds()*float64(d.size.Y)/hazeRiseSec) % d.size.Y
+		region := d.haze.Region(image.Rect(0, max(0, offset), d.size.X, max(0, offset)+d.size.Y))
+		defer region.Close()
+		blendTowards(f, region, strength, func(dst *gocv.Mat) {
+			dst.SetTo(gocv.NewScalar(hazeColor, hazeColor, hazeColor, 0))
+		})
+	case "smudges":
+		sigma := float64(d.size.X) / 60
+		blendTowards(f, d.smudges, strength, func(dst *gocv.Mat) {
+			gocv.GaussianBlur(*f, dst, image.Point{}, sigma, sigma, gocv.BorderReflect101)
+		})
+	case "defocus_blur":
+		radius := int(math.Round(strength))
+		if radius < 1 {
+			return
+		}
+		kernel := gocv.Zeros(2*radius+1, 2*radius+1, gocv.MatTypeCV32FC1)
+		defer kernel.Close()
+		gocv.Circle(&kernel, image.Pt(radius, radius), radius, color.RGBA{1, 1, 1, 1}, -1)
+		convolve(f, kernel)
+	case "motion_blur":
+		half := int(math.Round(strength / 2))
+		if half < 1 {
+			return
+		}
+		angle := 0.0
+		if e.AngleDeg != nil {
+			angle = *e.AngleDeg * math.Pi / 180
+		}
+		dx, dy := int(math.Round(math.Cos(angle)*float64(half))), int(math.Round(math.Sin(angle)*float64(half)))
+		kernel := gocv.Zeros(2*half+1, 2*half+1, gocv.MatTypeCV32FC1)
+		defer kernel.Close()
+		gocv.Line(&kernel, image.Pt(half-dx, half-dy), image.Pt(half+dx, half+dy), color.RGBA{1, 1, 1, 1}, 1)
+		convolve(f, kernel)
+	case "exposure":
+		f.MultiplyFloat(float32(math.Pow(2, strength)))
+	case "noise":
+		x, y := d.rng.Intn(noiseTileSize), d.rng.Intn(noiseTileSize)
+		region := d.noise.Region(image.Rect(x, y, x+d.size.X, y+d.size.Y))
+		defer region.Close()
+		gocv.ScaleAdd(region, strength, *f, f)
+	}
+}
+
+// occlude covers fraction of the frame with a dark blob
+func (d *degrader) occlude(f *gocv.Mat, fraction float64) {
+	o := d.occluder
+	area := fraction * float64(d.size.X*d.size.Y)
+	width := math.Sqrt(area / (math.Pi * o.aspect))
+	axes := image.Pt(int(math.Round(width)), int(math.Round(width*o.aspect)))
+	if axes.X < 1 || axes.Y < 1 {
+		return
+	}
+	gocv.Ellipse(f, o.center, axes, o.angle, 0, 360, color.RGBA{40, 40, 40, 0}, -1)
+}
+
+// blendTowards moves f towards a target image by mask*strength per pixel;
+// fill draws the target into the Mat it is given
+func blendTowards(f *gocv.Mat, mask gocv.Mat, strength float64, fill func(dst *gocv.Mat)) {
+	target := gocv.NewMatWithSize(f.Rows(), f.Cols(), gocv.MatTypeCV32FC3)
+	defer target.Close()
+	fill(&target)
+	alpha := gocv.NewMat()
+	defer alpha.Close()
+	mask.ConvertToWithParams(&alpha, gocv.MatTypeCV32FC3, float32(strength), 0)
+
+	gocv.Subtract(target, *f, &target)
+	gocv.Multiply(target, alpha, &target)
+	gocv.Add(*f, target, f)
+}
+
+// convolve filters f with kernel after normalizing it to sum to 1
+func convolve(f *gocv.Mat, kernel gocv.Mat) {
+	kernel.DivideFloat(float32(gocv.CountNonZero(kernel)))
+	blurred := gocv.NewMat()
+	gocv.Filter2D(*f, &blurred, -1, kernel, image.Pt(-1, -1), 0, gocv.BorderReflect101)
+	f.Close()
+	*f = blurred
+}
+
+// recompress round-trips frame through JPEG at quality, taking ownership of
+// frame; it is returned unchanged if encoding fails
+func recompress(frame gocv.Mat, quality int) gocv.Mat {
+	data, err := imEncode(gocv.JPEGFileExt, frame, []int{gocv.IMWriteJpegQuality, max(1, quality)})
+	if err != nil {
+		return frame
+	}
+	decoded, err := gocv.IMDecode(data, gocv.IMReadColor)
+	if err != nil || decoded.Empty() {
+		decoded.Close()
+		return frame
+	}
+	frame.Close()
+	return decoded
+}
+
+// prepare draws the random layers for a frame size, from a source seeded only
+// by the seed so they don't depend on how many frames came before. Callers
+// must hold mu.
+func (d *degrader) prepare(width, height int) error {
+	size := image.Pt(width, height)
+	if size == d.size {
+		return nil
+	}
+	d.releaseLayers()
+	rng := rand.New(rand.NewSource(d.seed))
+
+	tile := make([]float32, noiseTileSize*noiseTileSize*3)
+	for i := range tile {
+		tile[i] = float32(rng.NormFloat64())
+	}
+	noiseTile, err := floatMat(noiseTileSize, noiseTileSize, gocv.MatTypeCV32FC3, tile)
+	if err != nil {
+		return err
+	}
+	defer noiseTile.Close()
+	d.noise = gocv.NewMat()
+	gocv.Repeat(noiseTile, height/noiseTileSize+2, width/noiseTileSize+2, &d.noise)
+
+	// steam: smooth random density, mirrored below itself so it wraps seamlessly as it rises
+	coarse := make([]float32, 6*8)
+	for i := range coarse {
+		coarse[i] = float32(0.35 + 0.65*rng.Float64())
+	}
+	coarseMat, err := floatMat(6, 8, gocv.MatTypeCV32FC1, coarse)
+	if err != nil {
+		return err
+	}
+	defer coarseMat.Close()
+	density, flipped, rising := gocv.NewMat(), gocv.NewMat(), gocv.NewMat()
+	defer density.Close()
+	defer flipped.Close()
+	defer rising.Close()
+	gocv.Resize(coarseMat, &density, size, 0, 0, gocv.InterpolationCubic)
+	gocv.Flip(density, &flipped, 0)
+	gocv.Vconcat(density, flipped, &rising)
+	d.haze = gocv.NewMat()
+	gocv.Merge([]gocv.Mat{rising, rising, rising}, &d.haze)
+
+	count := 4
+	if d.cfg.Smudges != nil && d.cfg.Smudges.Count != nil {
+		count = *d.cfg.Smudges.Count
+	}
+	mask := gocv.Zeros(height, width, gocv.MatTypeCV8UC1)
+	defer mask.Close()
+	for i := 0; i < count; i++ {
+		center := image.Pt(rng.Intn(width), rng.Intn(height))
+		axes := image.Pt(width/16+rng.Intn(width/8+1), height/16+rng.Intn(height/8+1))
+		gocv.Ellipse(&mask, center, axes, rng.Float64()*180, 0, 360, color.RGBA{255, 255, 255, 0}, -1)
+	}
+	sigma := float64(width) / 40
+	gocv.GaussianBlur(mask, &mask, image.Point{}, sigma, sigma, gocv.BorderReflect101)
+	opacity := gocv.NewMat()
+	defer opacity.Close()
+	mask.ConvertToWithParams(&opacity, gocv.MatTypeCV32FC1, 1.0/255, 0)
+	d.smudges = gocv.NewMat()
+	gocv.Merge([]gocv.Mat{opacity, opacity, opacity}, &d.smudges)
+
+	d.occluder = occluder{
+		center: image.Pt(rng.Intn(width), rng.Intn(height)),
+		aspect: 0.5 + 1.5*rng.Float64(),
+		angle:  rng.Float64() * 180,
+	}
+	d.size = size
+	return nil
+}
+
+// floatMat copies float32 values into a new Mat
+func floatMat(rows, cols int, mt gocv.MatType, values []float32) (gocv.Mat, error) {
+	data := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(v))
+	}
+	mat, err := gocv.NewMatFromBytes(rows, cols, mt, data)
+	if err != nil {
+		return gocv.Mat{}, err
+	}
+	defer mat.Close()
+	return mat.Clone(), nil
+}
+
+// releaseLayers frees the random layers. Callers must hold mu.
+func (d *degrader) releaseLayers() {
+	if d.size == (image.Point{}) {
+		return
+	}
+	d.noise.Close()
+	d.haze.Close()
+	d.smudges.Close()
+	d.size = image.Point{}
+}
+
+// close frees the degrader's layers; frames applied afterwards are untouched
+func (d *degrader) close() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	d.releaseLayers()
+}
+
+// stats reports the seed and each effect's intensity on the last frame
+func (d *degrader) stats() map[string]interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return map[string]interface{}{
+		"seed":            d.seed,
+		"degraded_frames": d.frames,
+		"levels":          d.levels,
+	}
+}
+
+// startDegradations creates the degrader for the current config, releasing
+// the previous one. The update loop must not be running.
+func (s *videoReplayVideo) startDegradations() {
+	d := newDegrader(s.cfg.Degradations)
+	s.degrader.Swap(d).close()
+	if d != nil {
+		s.logger.Infof("[startDegradations] Degrading frames of %q with seed %d", s.name, d.seed)
+	}
+}
+
+// degradationStats reports the applied degradations, or nil without them
+func (s *videoReplayVideo) degradationStats() map[string]interface{} {
+	if d := s.degrader.Load(); d != nil {
+		return d.stats()
+	}
+	return nil
+}
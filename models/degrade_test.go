@@ -0,0 +1,106 @@
+package models
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDegradationLevel(t *testing.T) {
+	value := func(v float64) *float64 { return &v }
+	ramp := []IntensityKey{{AtSec: 10, Intensity: 0}, {AtSec: 20, Intensity: 1}, {AtSec: 30, Intensity: 0.5}}
+	tests := []struct {
+		name string
+		deg  Degradation
+		at   time.Duration
+		want float64
+	}{
+		{name: "full by default", deg: Degradation{}, at: time.Minute, want: 1},
+		{name: "constant intensity", deg: Degradation{Intensity: value(0.4)}, at: time.Minute, want: 0.4},
+		{name: "before the first key", deg: Degradation{Schedule: ramp}, at: 5 * time.Second, want: 0},
+		{name: "at a key", deg: Degradation{Schedule: ramp}, at: 20 * time.Second, want: 1},
+		{name: "rising", deg: Degradation{Schedule: ramp}, at: 12500 * time.Millisecond, want: 0.25},
+		{name: "falling", deg: Degradation{Schedule: ramp}, at: 25 * time.Second, want: 0.75},
+		{name: "after the last key", deg: Degradation{Schedule: ramp}, at: time.Hour, want: 0.5},
+		{name: "negative time", deg: Degradation{Schedule: []IntensityKey{{AtSec: 0, Intensity: 0.2}, {AtSec: 1, Intensity: 1}}}, at: -time.Second, want: 0.2},
+		{name: "schedule wins over intensity", deg: Degradation{Intensity: value(0.9), Schedule: ramp}, at: 0, want: 0},
+		{name: "repeated", deg: Degradation{Schedule: ramp, RepeatSec: value(40)}, at: 52500 * time.Millisecond, want: 0.25},
+		{name: "repeated before the first key", deg: Degradation{Schedule: ramp, RepeatSec: value(40)}, at: 45 * time.Second, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := namedDegradation{name: "haze", Degradation: &tt.deg}
+			if got := e.level(tt.at); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("level(%v) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDegradationAmount(t *testing.T) {
+	amount := 3.0
+	tests := []struct {
+		name string
+		e    namedDegradation
+		want float64
+	}{
+		{name: "configured", e: namedDegradation{name: "noise", Degradation: &Degradation{Amount: &amount}}, want: 3},
+		{name: "noise default", e: namedDegradation{name: "noise", Degradation: &Degradation{}}, want: 20},
+		{name: "jpeg default", e: namedDegradation{name: "jpeg", Degradation: &Degradation{}}, want: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.e.amount(); got != tt.want {
+				t.Errorf("amount() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDegradationConfigValidate(t *testing.T) {
+	value := func(v float64) *float64 { return &v }
+	count := 0
+	tests := []struct {
+		name    string
+		cfg     DegradationConfig
+		wantErr string
+	}{
+		{name: "no effects", cfg: DegradationConfig{}, wantErr: "at least one effect"},
+		{name: "defaults", cfg: DegradationConfig{Haze: &Degradation{}, Exposure: &Degradation{Amount: value(-2)}}},
+		{
+			name: "schedule",
+			cfg:  DegradationConfig{Noise: &Degradation{Schedule: []IntensityKey{{AtSec: 0, Intensity: 0}, {AtSec: 5, Intensity: 1}}, RepeatSec: value(10)}},
+		},
+		{name: "intensity above 1", cfg: DegradationConfig{Haze: &Degradation{Intensity: value(1.5)}}, wantErr: "degradations.haze: intensity"},
+		{
+			name:    "keys out of order",
+			cfg:     DegradationConfig{Haze: &Degradation{Schedule: []IntensityKey{{AtSec: 5}, {AtSec: 5}}}},
+			wantErr: "degradations.haze: schedule[1].at_sec",
+		},
+		{
+			name:    "key intensity out of range",
+			cfg:     DegradationConfig{Haze: &Degradation{Schedule: []IntensityKey{{AtSec: 0, Intensity: -0.1}}}},
+			wantErr: "schedule[0].intensity",
+		},
+		{name: "zero repeat", cfg: DegradationConfig{Noise: &Degradation{RepeatSec: value(0)}}, wantErr: "repeat_sec must be positive"},
+		{name: "zero smudges", cfg: DegradationConfig{Smudges: &Degradation{Count: &count}}, wantErr: "count must be at least 1"},
+		{name: "occlusion over 1", cfg: DegradationConfig{Occlusion: &Degradation{Amount: value(1.2)}}, wantErr: "degradations.occlusion: amount must be between 0 and 1"},
+		{name: "jpeg quality 0", cfg: DegradationConfig{JPEG: &Degradation{Amount: value(0)}}, wantErr: "quality between 1 and 100"},
+		{name: "negative blur", cfg: DegradationConfig{DefocusBlur: &Degradation{Amount: value(-1)}}, wantErr: "must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
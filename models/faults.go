@@ -0,0 +1,175 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// defaultBurstLength is the longest burst of dropped frames when
+// burst_length isn't configured
+const defaultBurstLength = 10
+
+// FaultConfig makes the update loop misbehave like a flaky camera. Every
+// decision comes from one random source, seeded by seed, so a run can be
+// reproduced exactly.
+type FaultConfig struct {
+	DropRate      *float64 `json:"drop_rate,omitempty"`      // probability each frame is dropped, 0-1
+	BurstRate     *float64 `json:"burst_rate,omitempty"`     // probability each frame starts a burst of drops, 0-1
+	BurstLength   *int     `json:"burst_length,omitempty"`   // longest burst, in frames; each burst is 1 to burst_length long (default 10)
+	JitterMs      *float64 `json:"jitter_ms,omitempty"`      // each frame is shown up to this many milliseconds late
+	DuplicateRate *float64 `json:"duplicate_rate,omitempty"` // probability a frame slot repeats the previous frame instead, 0-1
+	Seed          *int64   `json:"seed,omitempty"`           // random seed; a time-based seed is used, and logged, when unset
+}
+
+// validate checks the fault rates and sizes
+func (c *FaultConfig) validate() error {
+	for name, rate := range map[string]*float64{
+		"drop_rate":      c.DropRate,
+		"burst_rate":     c.BurstRate,
+		"duplicate_rate": c.DuplicateRate,
+	} {
+		if rate != nil && (*rate < 0 || *rate > 1) {
+			return fmt.Errorf("faults.%s must be between 0 and 1, got %v", name, *rate)
+		}
+	}
+	if c.BurstLength != nil && *c.BurstLength < 1 {
+		return fmt.Errorf("faults.burst_length must be at least 1, got %d", *c.BurstLength)
+	}
+	if c.JitterMs != nil && *c.JitterMs < 0 {
+		return fmt.Errorf("faults.jitter_ms must not be negative, got %v", *c.JitterMs)
+	}
+	return nil
+}
+
+// faultAction is what the update loop does with a frame slot
+type faultAction int
+
+const (
+	faultNone      faultAction = iota // show the frame
+	faultDrop                         // skip the frame; the previous one stays current
+	faultDuplicate                    // publish the previous frame again in place of this one
+)
+
+// faultInjector decides the faults of one playback run. A nil injector never
+// injects anything.
+type faultInjector struct {
+	cfg  *FaultConfig
+	seed int64
+
+	mu         sync.Mutex
+	rng        *rand.Rand
+	burstLeft  int
+	dropped    uint64
+	bursts     uint64
+	duplicated uint64
+}
+
+// newFaultInjector returns an injector for cfg, or nil when faults aren't configured
+func newFaultInjector(cfg *FaultConfig) *faultInjector {
+	if cfg == nil {
+		return nil
+	}
+	seed := time.Now().UnixNano()
+	if cfg.Seed != nil {
+		seed = *cfg.Seed
+	}
+	return &faultInjector{cfg: cfg, seed: seed, rng: rand.New(rand.NewSource(seed))}
+}
+
+// chance reports whether an event with probability rate happens. Callers must hold mu.
+func (f *faultInjector) chance(rate *float64) bool {
+	return rate != nil && *rate > 0 && f.rng.Float64() < *rate
+}
+
+// next decides what happens to the next frame slot
+func (f *faultInjector) next() faultAction {
+	if f == nil {
+		return faultNone
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.burstLeft > 0 {
+		f.burstLeft--
+		f.dropped++
+		return faultDrop
+	}
+	if f.chance(f.cfg.BurstRate) {
+		length := defaultBurstLength
+		if f.cfg.BurstLength != nil {
+			length = *f.cfg.BurstLength
+		}
+		f.burstLeft = f.rng.Intn(length)
+		f.bursts++
+		f.dropped++
+		return faultDrop
+	}
+	if f.chance(f.cfg.DropRate) {
+		f.dropped++
+		return faultDrop
+	}
+	if f.chance(f.cfg.DuplicateRate) {
+		f.duplicated++
+		return faultDuplicate
+	}
+	return faultNone
+}
+
+// jitter returns how late to show the next frame
+func (f *faultInjector) jitter() time.Duration {
+	if f == nil || f.cfg.JitterMs == nil || *f.cfg.JitterMs <= 0 {
+		return 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return time.Duration(f.rng.Float64() * *f.cfg.JitterMs * float64(time.Millisecond))
+}
+
+// stats reports the faults injected so far and the seed that reproduces them
+func (f *faultInjector) stats() map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return map[string]interface{}{
+		"seed":              f.seed,
+		"dropped_frames":    f.dropped,
+		"drop_bursts":       f.bursts,
+		"duplicated_frames": f.duplicated,
+	}
+}
+
+// startFaults creates the fault injector for a new playback run, so runs with
+// the same seed inject the same faults
+func (s *videoReplayVideo) startFaults() *faultInjector {
+	faults := newFaultInjector(s.cfg.Faults)
+	s.faults.Store(faults)
+	if faults != nil {
+		s.logger.Infof("[startFaults] Injecting faults into %q with seed %d", s.name, faults.seed)
+	}
+	return faults
+}
+
+// faultStats reports the current run's injected faults, or nil without faults
+func (s *videoReplayVideo) faultStats() map[string]interface{} {
+	if faults := s.faults.Load(); faults != nil {
+		return faults.stats()
+	}
+	return nil
+}
+
+// sleepCtx waits for d, returning false if ctx ends first
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
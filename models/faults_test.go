@@ -0,0 +1,124 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFaultConfigValidate(t *testing.T) {
+	rate := func(v float64) *float64 { return &v }
+	length := func(v int) *int { return &v }
+	tests := []struct {
+		name    string
+		cfg     FaultConfig
+		wantErr string
+	}{
+		{name: "empty", cfg: FaultConfig{}},
+		{name: "valid", cfg: FaultConfig{DropRate: rate(0.1), BurstRate: rate(1), BurstLength: length(1), JitterMs: rate(0), DuplicateRate: rate(0)}},
+		{name: "drop rate above 1", cfg: FaultConfig{DropRate: rate(1.5)}, wantErr: "faults.drop_rate"},
+		{name: "negative burst rate", cfg: FaultConfig{BurstRate: rate(-0.1)}, wantErr: "faults.burst_rate"},
+		{name: "duplicate rate above 1", cfg: FaultConfig{DuplicateRate: rate(2)}, wantErr: "faults.duplicate_rate"},
+		{name: "zero burst length", cfg: FaultConfig{BurstLength: length(0)}, wantErr: "faults.burst_length"},
+		{name: "negative jitter", cfg: FaultConfig{JitterMs: rate(-1)}, wantErr: "faults.jitter_ms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// faultRun returns the first n decisions and jitters of a fresh injector for cfg
+func faultRun(cfg *FaultConfig, n int) ([]faultAction, []time.Duration) {
+	f := newFaultInjector(cfg)
+	actions := make([]faultAction, n)
+	jitters := make([]time.Duration, n)
+	for i := range actions {
+		actions[i] = f.next()
+		jitters[i] = f.jitter()
+	}
+	return actions, jitters
+}
+
+func TestFaultInjectorSeed(t *testing.T) {
+	rate, burst, jitter := 0.2, 3, 40.0
+	seeded := func(seed int64) *FaultConfig {
+		return &FaultConfig{DropRate: &rate, BurstRate: &rate, BurstLength: &burst, DuplicateRate: &rate, JitterMs: &jitter, Seed: &seed}
+	}
+
+	actions, jitters := faultRun(seeded(42), 500)
+	againActions, againJitters := faultRun(seeded(42), 500)
+	if !reflect.DeepEqual(actions, againActions) || !reflect.DeepEqual(jitters, againJitters) {
+		t.Error("runs with the same seed injected different faults")
+	}
+	otherActions, _ := faultRun(seeded(43), 500)
+	if reflect.DeepEqual(actions, otherActions) {
+		t.Error("runs with different seeds injected the same faults")
+	}
+
+	counts := map[faultAction]int{}
+	for _, a := range actions {
+		counts[a]++
+	}
+	if counts[faultNone] == 0 || counts[faultDrop] == 0 || counts[faultDuplicate] == 0 {
+		t.Errorf("500 slots at 20%% rates gave %v, want every action", counts)
+	}
+	for i, j := range jitters {
+		if j < 0 || j >= 40*time.Millisecond {
+			t.Fatalf("jitter %d = %v, want within [0, 40ms)", i, j)
+		}
+	}
+}
+
+func TestFaultInjectorNext(t *testing.T) {
+	zero, one, seed := 0.0, 1.0, int64(7)
+	burst := 1
+	tests := []struct {
+		name        string
+		cfg         *FaultConfig
+		want        faultAction
+		wantBursts  uint64
+		wantDropped uint64
+		wantDup     uint64
+	}{
+		{name: "nil config", cfg: nil, want: faultNone},
+		{name: "no faults", cfg: &FaultConfig{Seed: &seed}, want: faultNone},
+		{name: "zero rates", cfg: &FaultConfig{DropRate: &zero, BurstRate: &zero, DuplicateRate: &zero, Seed: &seed}, want: faultNone},
+		{name: "always drop", cfg: &FaultConfig{DropRate: &one, Seed: &seed}, want: faultDrop, wantDropped: 10},
+		{name: "always duplicate", cfg: &FaultConfig{DuplicateRate: &one, Seed: &seed}, want: faultDuplicate, wantDup: 10},
+		{name: "single-frame bursts", cfg: &FaultConfig{BurstRate: &one, BurstLength: &burst, Seed: &seed}, want: faultDrop, wantBursts: 10, wantDropped: 10},
+		{name: "drops before duplicates", cfg: &FaultConfig{DropRate: &one, DuplicateRate: &one, Seed: &seed}, want: faultDrop, wantDropped: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFaultInjector(tt.cfg)
+			for i := range 10 {
+				if got := f.next(); got != tt.want {
+					t.Fatalf("slot %d = %v, want %v", i, got, tt.want)
+				}
+				if j := f.jitter(); j != 0 {
+					t.Fatalf("slot %d jitter = %v without jitter_ms", i, j)
+				}
+			}
+			if f == nil {
+				return
+			}
+			stats := f.stats()
+			if stats["seed"] != seed || stats["drop_bursts"] != tt.wantBursts ||
+				stats["dropped_frames"] != tt.wantDropped || stats["duplicated_frames"] != tt.wantDup {
+				t.Errorf("stats = %v", stats)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package models
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"math"
+	"sync"
+
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/utils"
+	"gocv.io/x/gocv"
+)
+
+// MimeTypeWebP is not defined by rdk/utils, so we define it here
+const MimeTypeWebP = "image/webp"
+
+// webPProbe is a 1x1 lossless WebP image
+const webPProbe = "UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA=="
+
+// webPSupported reports whether this OpenCV build handles WebP. OpenCV aborts
+// when asked to encode a format it has no encoder for, so the build is probed
+// by decoding a tiny image instead; its WebP encoder and decoder come together.
+var webPSupported = sync.OnceValue(func() bool {
+	data, err := base64.StdEncoding.DecodeString(webPProbe)
+	if err != nil {
+		return false
+	}
+	img, err := gocv.IMDecode(data, gocv.IMReadUnchanged)
+	if err != nil {
+		return false
+	}
+	defer img.Close()
+	return !img.Empty()
+})
+
+// colorMimeTypes returns the formats Image can produce from any frame, default first
+func colorMimeTypes() []string {
+	types := []string{utils.MimeTypeJPEG, utils.MimeTypePNG}
+	if webPSupported() {
+		types = append(types, MimeTypeWebP)
+	}
+	return append(types, utils.MimeTypeQOI, utils.MimeTypeRawRGBA)
+}
+
+// isDepthFrame reports whether a frame holds 16-bit single channel data, e.g. a
+// depth PNG from a dataset, which can also be served as raw depth
+func isDepthFrame(frame gocv.Mat) bool {
+	return frame.Type() == gocv.MatTypeCV16UC1
+}
+
+// supportedMimeTypes lists the formats Image can produce for a color or depth frame
+func supportedMimeTypes(depth bool) []string {
+	types := colorMimeTypes()
+	if depth {
+		types = append(types, utils.MimeTypeRawDepth)
+	}
+	return types
+}
+
+// resolveMimeType strips the lazy suffix from a requested MIME type, using
+// JPEG when none is requested. A type that can't be produced for the frame is
+// an error rather than being quietly swapped for another.
+func resolveMimeType(requested string, depth bool) (string, error) {
+	mimeType, _ := utils.CheckLazyMIMEType(requested)
+	if mimeType == "" {
+		return utils.MimeTypeJPEG, nil
+	}
+	supported := supportedMimeTypes(depth)
+	for _, t := range supported {
+		if mimeType == t {
+			return mimeType, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported mime type %q: must be one of %v", requested, supported)
+}
+
+// decodeFrame decodes dataset image bytes. 8-bit images always come back as BGR;
+// 16-bit single channel images (depth) keep their full range.
+func decodeFrame(data []byte) (gocv.Mat, error) {
+	frame, err := gocv.IMDecode(data, gocv.IMReadAnyColor|gocv.IMReadAnyDepth)
+	if err != nil {
+		return gocv.Mat{}, err
+	}
+	if frame.Empty() {
+		frame.Close()
+		return gocv.Mat{}, fmt.Errorf("decoded to an empty image")
+	}
+	if isDepthFrame(frame) {
+		return frame, nil
+	}
+
+	// Anything else is normalized to 8-bit BGR like IMReadColor would
+	if frame.Type() != gocv.MatTypeCV8UC3 {
+		bgr := gocv.NewMat()
+		switch frame.Channels() {
+		case 1:
+			gocv.CvtColor(frame, &bgr, gocv.ColorGrayToBGR)
+		case 4:
+			gocv.CvtColor(frame, &bgr, gocv.ColorBGRAToBGR)
+		default:
+			frame.CopyTo(&bgr)
+		}
+		frame.Close()
+		frame = bgr
+		if frame.Type() != gocv.MatTypeCV8UC3 {
+			converted := gocv.NewMat()
+			frame.ConvertTo(&converted, gocv.MatTypeCV8UC3)
+			frame.Close()
+			frame = converted
+		}
+	}
+	return frame, nil
+}
+
+// encodeOptions are the encoder settings for JPEG/WebP quality and PNG
+// compression. A negative value leaves the OpenCV default in place.
+type encodeOptions struct {
+	jpegQuality    int
+	pngCompression int
+}
+
+// defaultEncodeOptions uses the OpenCV defaults for everything
+var defaultEncodeOptions = encodeOptions{jpegQuality: -1, pngCompression: -1}
+
+// Keys in Image's extra map that override the configured encode options
+const (
+	extraJPEGQuality    = "jpeg_quality"
+	extraPNGCompression = "png_compression"
+)
+
+// encodeOptions returns the configured encode options
+func (c *Config) encodeOptions() encodeOptions {
+	opts := defaultEncodeOptions
+	if c.JPEGQuality != nil {
+		opts.jpegQuality = *c.JPEGQuality
+	}
+	if c.PNGCompression != nil {
+		opts.pngCompression = *c.PNGCompression
+	}
+	return opts
+}
+
+// withOverrides applies per-request overrides from extra and checks the
+// resulting options are in range
+func (o encodeOptions) withOverrides(extra map[string]interface{}) (encodeOptions, error) {
+	if v, ok := extra[extraJPEGQuality]; ok {
+		q, err := extraInt(extraJPEGQuality, v)
+		if err != nil {
+			return o, err
+		}
+		o.jpegQuality = q
+	}
+	if v, ok := extra[extraPNGCompression]; ok {
+		c, err := extraInt(extraPNGCompression, v)
+		if err != nil {
+			return o, err
+		}
+		o.pngCompression = c
+	}
+
+	if o.jpegQuality != -1 && (o.jpegQuality < 1 || o.jpegQuality > 100) {
+		return o, fmt.Errorf("jpeg_quality must be between 1 and 100, got %d", o.jpegQuality)
+	}
+	if o.pngCompression != -1 && (o.pngCompression < 0 || o.pngCompression > 9) {
+		return o, fmt.Errorf("png_compression must be between 0 and 9, got %d", o.pngCompression)
+	}
+	return o, nil
+}
+
+// extraInt reads a whole number from extra, which arrives as float64 when it
+// came over the wire as JSON
+func extraInt(key string, v interface{}) (int, error) {
+	if n, ok := numberValue(v); ok && n == math.Trunc(n) {
+		return int(n), nil
+	}
+	return 0, fmt.Errorf("%s must be a whole number, got %v", key, v)
+}
+
+// numberValue reads a number from a DoCommand or extra map: float64 when it
+// came over the wire as JSON, or any Go numeric type from in-process callers
+func numberValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
+
+// params returns the IMEncode parameters for the given extension
+func (o encodeOptions) params(ext gocv.FileExt) []int {
+	switch {
+	case ext == gocv.JPEGFileExt && o.jpegQuality > 0:
+		return []int{gocv.IMWriteJpegQuality, o.jpegQuality}
+	case ext == webPFileExt && o.jpegQuality > 0:
+		return []int{gocv.IMWriteWebpQuality, o.jpegQuality}
+	case ext == gocv.PNGFileExt && o.pngCompression >= 0:
+		return []int{gocv.IMWritePngCompression, o.pngCompression}
+	}
+	return nil
+}
+
+// cacheKey identifies an encoding of a frame in the given MIME type
+func (o encodeOptions) cacheKey(mimeType string) string {
+	switch mimeType {
+	case utils.MimeTypeJPEG, MimeTypeWebP:
+		return fmt.Sprintf("%s;q=%d", mimeType, o.jpegQuality)
+	case utils.MimeTypePNG:
+		return fmt.Sprintf("%s;c=%d", mimeType, o.pngCompression)
+	default:
+		return mimeType
+	}
+}
+
+// webPFileExt is not defined by gocv
+const webPFileExt = gocv.FileExt(".webp")
+
+// encodeFrame encodes a frame in the given (already resolved) MIME type
+func encodeFrame(ctx context.Context, frame gocv.Mat, mimeType string, opts encodeOptions) ([]byte, error) {
+	switch mimeType {
+	case utils.MimeTypeJPEG, MimeTypeWebP:
+		ext := gocv.JPEGFileExt
+		if mimeType == MimeTypeWebP {
+			ext = webPFileExt
+		}
+		// JPEG and WebP are 8-bit only
+		if isDepthFrame(frame) {
+			scaled := gocv.NewMat()
+			defer scaled.Close()
+			frame.ConvertToWithParams(&scaled, gocv.MatTypeCV8UC1, 1.0/256, 0)
+			return imEncode(ext, scaled, opts.params(ext))
+		}
+		return imEncode(ext, frame, opts.params(ext))
+	case utils.MimeTypePNG:
+		return imEncode(gocv.PNGFileExt, frame, opts.params(gocv.PNGFileExt))
+	case utils.MimeTypeQOI, utils.MimeTypeRawRGBA, utils.MimeTypeRawDepth:
+		img, err := matToImage(frame)
+		if err != nil {
+			return nil, err
+		}
+		return rimage.EncodeImage(ctx, img, mimeType)
+	default:
+		return nil, fmt.Errorf("unsupported mime type %q", mimeType)
+	}
+}
+
+// imEncode runs gocv.IMEncodeWithParams and copies the result out of native memory
+func imEncode(ext gocv.FileExt, frame gocv.Mat, params []int) ([]byte, error) {
+	buf, err := gocv.IMEncodeWithParams(ext, frame, params)
+	if err != nil {
+		return nil, err
+	}
+	defer buf.Close()
+	return append([]byte(nil), buf.GetBytes()...), nil
+}
+
+// matToImage converts a frame to an image.Image, including 16-bit depth frames
+// which gocv's ToImage does not handle
+func matToImage(frame gocv.Mat) (image.Image, error) {
+	if !isDepthFrame(frame) {
+		return frame.ToImage()
+	}
+	data, err := frame.DataPtrUint16()
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewGray16(image.Rect(0, 0, frame.Cols(), frame.Rows()))
+	for i, v := range data {
+		img.Pix[2*i] = uint8(v >> 8)
+		img.Pix[2*i+1] = uint8(v)
+	}
+	return img, nil
+}
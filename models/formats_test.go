@@ -0,0 +1,88 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"go.viam.com/rdk/utils"
+)
+
+func TestResolveMimeType(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested string
+		depth     bool
+		webP      bool
+		want      string
+		wantErr   string
+	}{
+		{name: "empty is JPEG", requested: "", want: utils.MimeTypeJPEG},
+		{name: "PNG", requested: utils.MimeTypePNG, want: utils.MimeTypePNG},
+		{name: "lazy suffix stripped", requested: utils.WithLazyMIMEType(utils.MimeTypePNG), want: utils.MimeTypePNG},
+		{name: "raw RGBA", requested: utils.MimeTypeRawRGBA, want: utils.MimeTypeRawRGBA},
+		{name: "WebP when supported", requested: MimeTypeWebP, webP: true, want: MimeTypeWebP},
+		{name: "WebP when not supported", requested: MimeTypeWebP, wantErr: "unsupported mime type"},
+		{name: "depth from a depth frame", requested: utils.MimeTypeRawDepth, depth: true, want: utils.MimeTypeRawDepth},
+		{name: "depth from a color frame", requested: utils.MimeTypeRawDepth, wantErr: "unsupported mime type"},
+		{name: "unknown type", requested: "image/gif", wantErr: `unsupported mime type "image/gif"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubWebPSupported(t, tt.webP)
+			got, err := resolveMimeType(tt.requested, tt.depth)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("resolveMimeType(%q, %v) = %q, want %q", tt.requested, tt.depth, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSupportedMimeTypes(t *testing.T) {
+	tests := []struct {
+		name  string
+		depth bool
+		webP  bool
+		want  []string
+	}{
+		{
+			name: "color",
+			want: []string{utils.MimeTypeJPEG, utils.MimeTypePNG, utils.MimeTypeQOI, utils.MimeTypeRawRGBA},
+		},
+		{
+			name: "color with WebP",
+			webP: true,
+			want: []string{utils.MimeTypeJPEG, utils.MimeTypePNG, MimeTypeWebP, utils.MimeTypeQOI, utils.MimeTypeRawRGBA},
+		},
+		{
+			name:  "depth",
+			depth: true,
+			want:  []string{utils.MimeTypeJPEG, utils.MimeTypePNG, utils.MimeTypeQOI, utils.MimeTypeRawRGBA, utils.MimeTypeRawDepth},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubWebPSupported(t, tt.webP)
+			if got := supportedMimeTypes(tt.depth); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("supportedMimeTypes(%v) = %v, want %v", tt.depth, got, tt.want)
+			}
+		})
+	}
+}
+
+// stubWebPSupported makes the OpenCV WebP probe report supported for the
+// rest of the test
+func stubWebPSupported(t *testing.T, supported bool) {
+	t.Helper()
+	probe := webPSupported
+	webPSupported = func() bool { return supported }
+	t.Cleanup(func() { webPSupported = probe })
+}
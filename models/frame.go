@@ -0,0 +1,458 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/utils"
+	"gocv.io/x/gocv"
+)
+
+// replayFrame is one frame of the replay. Frames from JPEG sources keep the
+// source bytes so they can be served without transcoding; their Mat is only
+// decoded when a caller needs pixels. Each output format is encoded at most
+// once and cached with the frame. Apart from the lazy decode and the cache, a
+// frame is never modified after it becomes current.
+//
+// Frames are reference counted: the camera holds one reference to the current
+// frame and readers retain it while they use it, so the Mat is only released
+// once the last reader is done.
+type replayFrame struct {
+	width, height int
+	meta          frameMeta
+	refs          atomic.Int32
+
+	// Original source bytes, served as-is when encodedType is requested
+	encoded     []byte
+	encodedType string
+
+	decodeOnce sync.Once
+	mat        gocv.Mat
+	hasMat     bool
+	decodeErr  error
+
+	cacheMu sync.Mutex
+	cache   map[string]*encodedEntry
+
+	imageOnce sync.Once
+	image     image.Image
+	imageErr  error
+}
+
+// frameMeta records when a frame was captured and where it came from
+type frameMeta struct {
+	capturedAt time.Time     // reported capture time, per timestamp_mode
+	recordedAt time.Time     // capture time in the recording
+	file       string        // video file or dataset filename
+	frameIndex int           // frame number within the video, or image index within the dataset
+	ptsMsec    float64       // presentation time within the video file, in milliseconds
+	position   time.Duration // time since the first frame of the recording, restarting each loop
+	binaryID   string        // Viam binary data ID for dataset and capture history sources
+	h264       []byte        // source H.264 access unit, passed through to RTP subscribers
+	h264Gap    bool          // access units before this one were skipped, so passthrough must resync
+	thermal    *thermalFrame // temperatures the frame was colormapped from, for thermal replays
+}
+
+// provenance reports the frame's metadata for DoCommand
+func (m frameMeta) provenance() map[string]interface{} {
+	info := map[string]interface{}{
+		"captured_at": m.capturedAt.UTC().Format(time.RFC3339Nano),
+		"recorded_at": m.recordedAt.UTC().Format(time.RFC3339Nano),
+		"file":        m.file,
+		"frame_index": m.frameIndex,
+	}
+	if m.ptsMsec > 0 {
+		info["pts_msec"] = m.ptsMsec
+	}
+	if m.binaryID != "" {
+		info["binary_id"] = m.binaryID
+	}
+	return info
+}
+
+// encodedEntry is one cached encoding of a frame
+type encodedEntry struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+// newMatFrame wraps an already decoded frame, taking ownership of mat
+func newMatFrame(mat gocv.Mat) *replayFrame {
+	f := &replayFrame{width: mat.Cols(), height: mat.Rows(), mat: mat, hasMat: true}
+	f.refs.Store(1)
+	f.decodeOnce.Do(func() {})
+	return f
+}
+
+// newEncodedFrame wraps source bytes, decoded on the first call to Mat
+func newEncodedFrame(data []byte, mimeType string, width, height int) *replayFrame {
+	f := &replayFrame{width: width, height: height, encoded: data, encodedType: mimeType}
+	f.refs.Store(1)
+	return f
+}
+
+// retain adds a reference; pair every retain with a release
+func (f *replayFrame) retain() {
+	f.refs.Add(1)
+}
+
+// release drops a reference and frees the Mat when the last one is gone
+func (f *replayFrame) release() {
+	if f.refs.Add(-1) == 0 && f.hasMat {
+		f.mat.Close()
+	}
+}
+
+// Mat returns the frame's pixels, decoding the source bytes on first use.
+// The Mat stays owned by the frame.
+func (f *replayFrame) Mat() (gocv.Mat, error) {
+	f.decodeOnce.Do(func() {
+		f.mat, f.decodeErr = decodeFrame(f.encoded)
+		f.hasMat = f.decodeErr == nil
+	})
+	return f.mat, f.decodeErr
+}
+
+// isDepth reports whether the frame holds 16-bit depth data. Encoded frames
+// are always JPEG, so never depth.
+func (f *replayFrame) isDepth() bool {
+	return f.encoded == nil && isDepthFrame(f.mat)
+}
+
+// Encode returns the frame in the given (already resolved) MIME type. The
+// source bytes are returned when they already match and no JPEG quality is
+// set; otherwise the frame is encoded once per format and options and shared
+// by every caller, who must not modify it.
+func (f *replayFrame) Encode(ctx context.Context, mimeType string, opts encodeOptions) ([]byte, error) {
+	if mimeType == f.encodedType && opts.jpegQuality <= 0 {
+		return f.encoded, nil
+	}
+
+	key := opts.cacheKey(mimeType)
+	f.cacheMu.Lock()
+	if f.cache == nil {
+		f.cache = map[string]*encodedEntry{}
+	}
+	entry, ok := f.cache[key]
+	if !ok {
+		entry = &encodedEntry{}
+		f.cache[key] = entry
+	}
+	f.cacheMu.Unlock()
+
+	entry.once.Do(func() {
+		mat, err := f.Mat()
+		if err != nil {
+			entry.err = err
+			return
+		}
+		entry.data, entry.err = encodeFrame(ctx, mat, mimeType, opts)
+	})
+	return entry.data, entry.err
+}
+
+// Image returns the frame as an image.Image, built once and shared by every
+// caller, who must not modify it. Source JPEG bytes are wrapped as a lazily
+// decoded image, so they can be forwarded as-is without re-encoding.
+func (f *replayFrame) Image() (image.Image, error) {
+	f.imageOnce.Do(func() {
+		if f.encoded != nil {
+			f.image = rimage.NewLazyEncodedImage(f.encoded, f.encodedType)
+			return
+		}
+		mat, err := f.Mat()
+		if err != nil {
+			f.imageErr = err
+			return
+		}
+		f.image, f.imageErr = matToImage(mat)
+	})
+	return f.image, f.imageErr
+}
+
+// jpegSize returns the dimensions of a complete baseline or progressive JPEG
+// without decoding it. It rejects truncated files and Motion-JPEG frames that
+// rely on default Huffman tables, since those can't be served as-is.
+func jpegSize(data []byte) (int, int, bool) {
+	if !bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}) {
+		return 0, 0, false
+	}
+	if !bytes.HasSuffix(bytes.TrimRight(data, "\x00"), []byte{0xFF, 0xD9}) {
+		return 0, 0, false
+	}
+	sos := bytes.Index(data, []byte{0xFF, 0xDA})
+	if sos < 0 || !bytes.Contains(data[:sos], []byte{0xFF, 0xC4}) {
+		return 0, 0, false
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, false
+	}
+	return cfg.Width, cfg.Height, true
+}
+
+// canPassthrough reports whether a source frame of the given size can be
+// served without any transform; degraded frames never can
+func (s *videoReplayVideo) canPassthrough(width, height int) bool {
+	return s.cfg.Degradations == nil && s.cfg.layout(width, height).identity
+}
+
+// setSourceBytes makes encoded source bytes the current frame. JPEGs that need
+// no transform are served as-is and only decoded if a raw format is requested;
+// jpegSize checks their structure, so a truncated or malformed one falls
+// through to the full decode and fails now, under on_decode_error. Anything
+// else is decoded and processed.
+func (s *videoReplayVideo) setSourceBytes(data []byte, meta frameMeta) error {
+	if width, height, ok := jpegSize(data); ok && s.canPassthrough(width, height) {
+		s.replaceFrame(newEncodedFrame(data, utils.MimeTypeJPEG, width, height), width, height, meta)
+		return nil
+	}
+	mat, err := decodeFrame(data)
+	if err != nil {
+		return err
+	}
+	s.setFrame(mat, meta)
+	return nil
+}
+
+// setFrame scales frame to the output size, applies any degradations and
+// makes it the current frame, taking ownership of frame
+func (s *videoReplayVideo) setFrame(frame gocv.Mat, meta frameMeta) {
+	srcWidth, srcHeight := frame.Cols(), frame.Rows()
+	frame = s.cfg.resizeFrame(frame)
+	if d := s.degrader.Load(); d != nil {
+		frame = d.apply(frame, meta.position)
+	}
+	s.replaceFrame(newMatFrame(frame), srcWidth, srcHeight, meta)
+}
+
+// replaceFrame swaps in a new current frame and releases the old one. meta
+// holds the recorded capture time, which is restamped per timestamp_mode.
+func (s *videoReplayVideo) replaceFrame(f *replayFrame, srcWidth, srcHeight int, meta frameMeta) {
+	meta.recordedAt = meta.capturedAt
+	meta.capturedAt = s.stampTime(meta.capturedAt)
+	f.meta = meta
+
+	s.frameMutex.Lock()
+	defer s.frameMutex.Unlock()
+	if s.current != nil {
+		s.recent = append(s.recent, s.current)
+	}
+	for len(s.recent) > s.staleDepth {
+		s.recent[0].release()
+		s.recent = s.recent[1:]
+	}
+	s.current = f
+	s.sourceWidth, s.sourceHeight = srcWidth, srcHeight
+	s.frameErr = nil
+	s.frames.publish(frameEvent{frame: f})
+}
+
+// duplicateFrame publishes the current frame again, as a camera repeating a
+// buffer would
+func (s *videoReplayVideo) duplicateFrame() {
+	s.frameMutex.Lock()
+	defer s.frameMutex.Unlock()
+	if s.current != nil {
+		s.frames.publish(frameEvent{frame: s.current, repeat: true})
+	}
+}
+
+// skipFrame passes over the grabbed frame without showing it. Its H.264
+// access unit is read and discarded so the raw capture stays in step, and the
+// next frame is marked so RTP subscribers resync at a keyframe instead of
+// decoding frames that reference the missing one.
+func (s *videoReplayVideo) skipFrame() {
+	if s.h264Capture == nil {
+		return
+	}
+	s.retrieveH264()
+	s.h264Gap = true
+}
+
+// acquireFrame returns the current frame, retained for the caller. The caller
+// must release the frame when done with it.
+func (s *videoReplayVideo) acquireFrame() (*replayFrame, error) {
+	s.frameMutex.RLock()
+	defer s.frameMutex.RUnlock()
+	if s.frameErr != nil {
+		return nil, s.frameErr
+	}
+	if s.current == nil {
+		return nil, fmt.Errorf("no frame available")
+	}
+	s.current.retain()
+	return s.current, nil
+}
+
+// acquireStaleFrame returns the frame shown age frames before the current
+// one, or the oldest kept, retained for the caller
+func (s *videoReplayVideo) acquireStaleFrame(age int) (*replayFrame, error) {
+	s.frameMutex.RLock()
+	defer s.frameMutex.RUnlock()
+	if s.frameErr != nil {
+		return nil, s.frameErr
+	}
+	frame := s.current
+	if len(s.recent) > 0 {
+		frame = s.recent[max(0, len(s.recent)-age)]
+	}
+	if frame == nil {
+		return nil, fmt.Errorf("no frame available")
+	}
+	frame.retain()
+	return frame, nil
+}
+
+// releaseFrames drops the current and previous frames. Callers must hold frameMutex.
+func (s *videoReplayVideo) releaseFrames() {
+	if s.current != nil {
+		s.current.release()
+		s.current = nil
+	}
+	for _, f := range s.recent {
+		f.release()
+	}
+	s.recent = nil
+}
+
+// setFrameError makes Image/Images fail with err until the next frame is set
+func (s *videoReplayVideo) setFrameError(err error) {
+	s.frameMutex.Lock()
+	defer s.frameMutex.Unlock()
+	s.frameErr = err
+	s.frames.publish(frameEvent{err: err})
+}
+
+// mjpegFourCCs are the codec tags Motion-JPEG files are stored under
+var mjpegFourCCs = map[string]bool{
+	"MJPG": true,
+	"mjpg": true,
+	"jpeg": true,
+	"mjpa": true,
+}
+
+// openCapture opens a video file. Motion-JPEG files that need no transform are
+// opened in raw mode so their JPEG packets can be passed through untouched.
+func (s *videoReplayVideo) openCapture(path string) (*gocv.VideoCapture, bool, error) {
+	cap, err := gocv.VideoCaptureFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	width := int(cap.Get(gocv.VideoCaptureFrameWidth))
+	height := int(cap.Get(gocv.VideoCaptureFrameHeight))
+	if !mjpegFourCCs[cap.CodecString()] || !s.canPassthrough(width, height) {
+		return cap, false, nil
+	}
+
+	// Raw mode is only supported by some backends; check it took effect
+	cap.Set(gocv.VideoCaptureFormat, -1)
+	raw := cap.Get(gocv.VideoCaptureFormat) == -1
+	if raw {
+		s.logger.Infof("[openCapture] %q is Motion-JPEG; passing JPEG frames through", path)
+	}
+	return cap, raw, nil
+}
+
+// setCapture makes cap the capture for path, replacing and closing any open one
+func (s *videoReplayVideo) setCapture(cap *gocv.VideoCapture, raw bool, path string) {
+	s.closeCapture()
+	s.videoCapture = cap
+	s.rawCapture = raw
+	s.h264Capture = s.openH264Capture(path)
+	s.filePts = -1
+}
+
+// closeCapture closes the open captures, if any
+func (s *videoReplayVideo) closeCapture() {
+	if s.videoCapture != nil {
+		s.videoCapture.Close()
+		s.videoCapture = nil
+	}
+	if s.h264Capture != nil {
+		s.h264Capture.Close()
+		s.h264Capture = nil
+	}
+}
+
+// rewindCapture seeks the open captures back to the first frame
+func (s *videoReplayVideo) rewindCapture() {
+	s.videoCapture.Set(gocv.VideoCapturePosFrames, 0)
+	if s.h264Capture != nil {
+		s.h264Capture.Set(gocv.VideoCapturePosFrames, 0)
+	}
+	s.filePts = -1
+}
+
+// readFrame reads the next frame from the capture and makes it current. It
+// returns false at the end of the file.
+func (s *videoReplayVideo) readFrame() bool {
+	return s.grabFrame() && s.retrieveFrame()
+}
+
+// grabFrame advances the captures to the next frame without decoding it, and
+// records its presentation time in filePts. It returns false at the end of
+// the file.
+func (s *videoReplayVideo) grabFrame() bool {
+	before := s.videoCapture.Get(gocv.VideoCapturePosFrames)
+	s.videoCapture.Grab(1)
+	if s.videoCapture.Get(gocv.VideoCapturePosFrames) == before {
+		return false
+	}
+	if s.h264Capture != nil {
+		s.h264Capture.Grab(1)
+	}
+	s.filePts = s.videoCapture.Get(gocv.VideoCapturePosMsec)
+	return true
+}
+
+// retrieveFrame decodes the grabbed frame and makes it current. The frame's
+// capture time is its presentation timestamp mapped onto the file's start
+// time.
+func (s *videoReplayVideo) retrieveFrame() bool {
+	mat := gocv.NewMat()
+	if ok := s.videoCapture.Retrieve(&mat); !ok || mat.Empty() {
+		mat.Close()
+		return false
+	}
+
+	file := s.videoFiles[s.videoIndex]
+	pts := s.filePts
+	s.lastPosition = s.fileOffset + time.Duration(pts*float64(time.Millisecond))
+	meta := frameMeta{
+		capturedAt: s.fileStart.Add(time.Duration(pts * float64(time.Millisecond))),
+		file:       file.name,
+		frameIndex: s.frameIndex(),
+		ptsMsec:    pts,
+		position:   s.lastPosition,
+		binaryID:   file.binaryID,
+		h264:       s.retrieveH264(),
+		h264Gap:    s.h264Gap,
+	}
+	s.h264Gap = false
+
+	// In raw mode a frame comes back as a single row of encoded bytes
+	if s.rawCapture && mat.Rows() == 1 {
+		data := mat.ToBytes()
+		mat.Close()
+		if err := s.setSourceBytes(data, meta); err != nil {
+			s.logger.Errorf("[readFrame] Failed to decode Motion-JPEG frame for %q: %v", s.name, err)
+		}
+		return true
+	}
+	s.setFrame(mat, meta)
+	return true
+}
+
+// frameIndex returns the index of the grabbed frame within the file
+func (s *videoReplayVideo) frameIndex() int {
+	return int(s.videoCapture.Get(gocv.VideoCapturePosFrames)) - 1
+}
@@ -0,0 +1,255 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Defaults for image_faults fields that aren't configured
+const (
+	defaultImageTimeoutMs = 10000
+	defaultStaleMaxFrames = 5
+)
+
+// errCameraDisconnected is returned by Image/Images during a disconnect window
+var errCameraDisconnected = errors.New("camera disconnected")
+
+// ImageFaultConfig makes Image and Images calls slow, fail or return old
+// frames, to exercise the failure handling of their callers. Every decision
+// comes from one random source, seeded by seed.
+type ImageFaultConfig struct {
+	DelayMs       *float64           `json:"delay_ms,omitempty"`         // fixed latency added to every call
+	DelayMeanMs   *float64           `json:"delay_mean_ms,omitempty"`    // mean of normally distributed extra latency
+	DelayStddevMs *float64           `json:"delay_stddev_ms,omitempty"`  // its standard deviation; negative samples add nothing
+	ErrorRate     *float64           `json:"error_rate,omitempty"`       // probability a call fails, 0-1
+	TimeoutRate   *float64           `json:"timeout_rate,omitempty"`     // probability a call hangs, then fails as timed out, 0-1
+	TimeoutMs     *int               `json:"timeout_ms,omitempty"`       // how long a timed out call hangs, unless its context ends first (default 10000)
+	Disconnects   []DisconnectWindow `json:"disconnects,omitempty"`      // windows in which every call fails with "camera disconnected"
+	StaleRate     *float64           `json:"stale_rate,omitempty"`       // probability a call returns an older frame, 0-1
+	StaleMaxAge   *int               `json:"stale_max_frames,omitempty"` // stale frames are 1 to this many frames old (default 5)
+	Seed          *int64             `json:"seed,omitempty"`             // random seed; a time-based seed is used, and logged, when unset
+}
+
+// DisconnectWindow is a span of time, counted from when the camera was
+// configured, in which the camera acts disconnected
+type DisconnectWindow struct {
+	StartSec    float64 `json:"start_sec"`           // when the window opens
+	DurationSec float64 `json:"duration_sec"`        // how long it stays open
+	EverySec    float64 `json:"every_sec,omitempty"` // repeat the window with this period; once if unset
+}
+
+// validate checks the rates, durations and windows
+func (c *ImageFaultConfig) validate() error {
+	for name, rate := range map[string]*float64{
+		"error_rate":   c.ErrorRate,
+		"timeout_rate": c.TimeoutRate,
+		"stale_rate":   c.StaleRate,
+	} {
+		if rate != nil && (*rate < 0 || *rate > 1) {
+			return fmt.Errorf("image_faults.%s must be between 0 and 1, got %v", name, *rate)
+		}
+	}
+	for name, ms := range map[string]*float64{
+		"delay_ms":        c.DelayMs,
+		"delay_mean_ms":   c.DelayMeanMs,
+		"delay_stddev_ms": c.DelayStddevMs,
+	} {
+		if ms != nil && *ms < 0 {
+			return fmt.Errorf("image_faults.%s must not be negative, got %v", name, *ms)
+		}
+	}
+	if c.TimeoutMs != nil && *c.TimeoutMs <= 0 {
+		return fmt.Errorf("image_faults.timeout_ms must be positive, got %d", *c.TimeoutMs)
+	}
+	if c.StaleMaxAge != nil && *c.StaleMaxAge < 1 {
+		return fmt.Errorf("image_faults.stale_max_frames must be at least 1, got %d", *c.StaleMaxAge)
+	}
+	for i, w := range c.Disconnects {
+		if w.StartSec < 0 || w.DurationSec <= 0 {
+			return fmt.Errorf("image_faults.disconnects[%d] needs a start_sec of at least 0 and a positive duration_sec", i)
+		}
+		if w.EverySec != 0 && w.EverySec <= w.DurationSec {
+			return fmt.Errorf("image_faults.disconnects[%d].every_sec must be longer than duration_sec", i)
+		}
+	}
+	return nil
+}
+
+// staleDepth returns how many previous frames to keep for stale serving
+func (c *Config) staleDepth() int {
+	if c.ImageFaults == nil || c.ImageFaults.StaleRate == nil || *c.ImageFaults.StaleRate <= 0 {
+		return 0
+	}
+	if c.ImageFaults.StaleMaxAge == nil {
+		return defaultStaleMaxFrames
+	}
+	return *c.ImageFaults.StaleMaxAge
+}
+
+// open reports whether the window is open at elapsed since the camera was configured
+func (w DisconnectWindow) open(elapsed time.Duration) bool {
+	t := elapsed.Seconds() - w.StartSec
+	if t < 0 {
+		return false
+	}
+	if w.EverySec > 0 {
+		t -= float64(int(t/w.EverySec)) * w.EverySec
+	}
+	return t < w.DurationSec
+}
+
+// imageFaultInjector decides the faults of Image and Images calls. A nil
+// injector never injects anything.
+type imageFaultInjector struct {
+	cfg   *ImageFaultConfig
+	seed  int64
+	start time.Time
+
+	mu           sync.Mutex
+	rng          *rand.Rand
+	delayed      uint64
+	errors       uint64
+	timeouts     uint64
+	disconnected uint64
+	stale        uint64
+}
+
+// newImageFaultInjector returns an injector for cfg, or nil when image faults
+// aren't configured
+func newImageFaultInjector(cfg *ImageFaultConfig) *imageFaultInjector {
+	if cfg == nil {
+		return nil
+	}
+	seed := time.Now().UnixNano()
+	if cfg.Seed != nil {
+		seed = *cfg.Seed
+	}
+	return &imageFaultInjector{cfg: cfg, seed: seed, start: time.Now(), rng: rand.New(rand.NewSource(seed))}
+}
+
+// chance reports whether an event with probability rate happens. Callers must hold mu.
+func (f *imageFaultInjector) chance(rate *float64) bool {
+	return rate != nil && *rate > 0 && f.rng.Float64() < *rate
+}
+
+// apply injects the faults of one call: it waits out the latency, then
+// returns an error for a failed call, or how many frames old the served frame
+// should be.
+func (f *imageFaultInjector) apply(ctx context.Context) (int, error) {
+	if f == nil {
+		return 0, nil
+	}
+
+	f.mu.Lock()
+	elapsed := time.Since(f.start)
+	for _, w := range f.cfg.Disconnects {
+		if w.open(elapsed) {
+			f.disconnected++
+			f.mu.Unlock()
+			return 0, errCameraDisconnected
+		}
+	}
+	var delay time.Duration
+	if f.cfg.DelayMs != nil {
+		delay += time.Duration(*f.cfg.DelayMs * float64(time.Millisecond))
+	}
+	if f.cfg.DelayMeanMs != nil || f.cfg.DelayStddevMs != nil {
+		mean, stddev := 0.0, 0.0
+		if f.cfg.DelayMeanMs != nil {
+			mean = *f.cfg.DelayMeanMs
+		}
+		if f.cfg.DelayStddevMs != nil {
+			stddev = *f.cfg.DelayStddevMs
+		}
+		delay += time.Duration(max(0, mean+stddev*f.rng.NormFloat64()) * float64(time.Millisecond))
+	}
+	if delay > 0 {
+		f.delayed++
+	}
+	timeout, fail := f.chance(f.cfg.TimeoutRate), f.chance(f.cfg.ErrorRate)
+	age := 0
+	if f.chance(f.cfg.StaleRate) {
+		maxAge := defaultStaleMaxFrames
+		if f.cfg.StaleMaxAge != nil {
+			maxAge = *f.cfg.StaleMaxAge
+		}
+		age = 1 + f.rng.Intn(maxAge)
+	}
+	switch {
+	case timeout:
+		f.timeouts++
+	case fail:
+		f.errors++
+	case age > 0:
+		f.stale++
+	}
+	f.mu.Unlock()
+
+	if timeout {
+		hang := time.Duration(defaultImageTimeoutMs) * time.Millisecond
+		if f.cfg.TimeoutMs != nil {
+			hang = time.Duration(*f.cfg.TimeoutMs) * time.Millisecond
+		}
+		sleepCtx(ctx, hang)
+		return 0, fmt.Errorf("image request timed out: %w", context.DeadlineExceeded)
+	}
+	if !sleepCtx(ctx, delay) {
+		return 0, ctx.Err()
+	}
+	if fail {
+		return 0, errors.New("injected image failure")
+	}
+	return age, nil
+}
+
+// stats reports the faults injected so far and the seed that reproduces them
+func (f *imageFaultInjector) stats() map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return map[string]interface{}{
+		"seed":         f.seed,
+		"delayed":      f.delayed,
+		"errors":       f.errors,
+		"timeouts":     f.timeouts,
+		"disconnected": f.disconnected,
+		"stale":        f.stale,
+	}
+}
+
+// acquireImageFrame injects the configured image faults and returns the frame
+// to serve, retained for the caller
+func (s *videoReplayVideo) acquireImageFrame(ctx context.Context) (*replayFrame, error) {
+	age, err := s.imageFaults.Load().apply(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if age > 0 {
+		return s.acquireStaleFrame(age)
+	}
+	return s.acquireFrame()
+}
+
+// startImageFaults creates the image fault injector for the current config;
+// disconnect windows count from now
+func (s *videoReplayVideo) startImageFaults() {
+	faults := newImageFaultInjector(s.cfg.ImageFaults)
+	s.imageFaults.Store(faults)
+	s.frameMutex.Lock()
+	s.staleDepth = s.cfg.staleDepth()
+	s.frameMutex.Unlock()
+	if faults != nil {
+		s.logger.Infof("[startImageFaults] Injecting image faults into %q with seed %d", s.name, faults.seed)
+	}
+}
+
+// imageFaultStats reports the injected image faults, or nil without them
+func (s *videoReplayVideo) imageFaultStats() map[string]interface{} {
+	if faults := s.imageFaults.Load(); faults != nil {
+		return faults.stats()
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDisconnectWindowOpen(t *testing.T) {
+	once := DisconnectWindow{StartSec: 2, DurationSec: 1.5}
+	repeating := DisconnectWindow{StartSec: 2, DurationSec: 1, EverySec: 5}
+	tests := []struct {
+		name    string
+		window  DisconnectWindow
+		elapsed time.Duration
+		want    bool
+	}{
+		{name: "before the window", window: once, elapsed: 1999 * time.Millisecond, want: false},
+		{name: "as it opens", window: once, elapsed: 2 * time.Second, want: true},
+		{name: "inside", window: once, elapsed: 3 * time.Second, want: true},
+		{name: "as it closes", window: once, elapsed: 3500 * time.Millisecond, want: false},
+		{name: "long after a one-off window", window: once, elapsed: time.Hour, want: false},
+		{name: "before a repeating window", window: repeating, elapsed: time.Second, want: false},
+		{name: "first repeat", window: repeating, elapsed: 2500 * time.Millisecond, want: true},
+		{name: "between repeats", window: repeating, elapsed: 4 * time.Second, want: false},
+		{name: "second repeat opens", window: repeating, elapsed: 7 * time.Second, want: true},
+		{name: "second repeat closes", window: repeating, elapsed: 8 * time.Second, want: false},
+		{name: "much later repeat", window: repeating, elapsed: 1002*time.Second + 500*time.Millisecond, want: true},
+		{name: "window at start", window: DisconnectWindow{DurationSec: 1}, elapsed: 0, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.open(tt.elapsed); got != tt.want {
+				t.Errorf("open(%v) = %v, want %v", tt.elapsed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageFaultConfigValidateDisconnects(t *testing.T) {
+	tests := []struct {
+		name    string
+		windows []DisconnectWindow
+		wantErr string
+	}{
+		{name: "valid", windows: []DisconnectWindow{{StartSec: 0, DurationSec: 1}, {StartSec: 5, DurationSec: 1, EverySec: 2}}},
+		{name: "negative start", windows: []DisconnectWindow{{StartSec: -1, DurationSec: 1}}, wantErr: "disconnects[0] needs"},
+		{name: "no duration", windows: []DisconnectWindow{{StartSec: 1}}, wantErr: "disconnects[0] needs"},
+		{name: "period not longer than duration", windows: []DisconnectWindow{{DurationSec: 1}, {DurationSec: 2, EverySec: 2}}, wantErr: "disconnects[1].every_sec"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&ImageFaultConfig{Disconnects: tt.windows}).validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestImageFaultInjectorDisconnect(t *testing.T) {
+	var none *imageFaultInjector
+	if age, err := none.apply(context.Background()); age != 0 || err != nil {
+		t.Errorf("nil injector apply = %d, %v", age, err)
+	}
+
+	f := newImageFaultInjector(&ImageFaultConfig{Disconnects: []DisconnectWindow{{DurationSec: 60}}})
+	for range 3 {
+		if _, err := f.apply(context.Background()); !errors.Is(err, errCameraDisconnected) {
+			t.Fatalf("apply in an open window = %v, want %v", err, errCameraDisconnected)
+		}
+	}
+	if f.disconnected != 3 {
+		t.Errorf("counted %d disconnected calls, want 3", f.disconnected)
+	}
+
+	f = newImageFaultInjector(&ImageFaultConfig{Disconnects: []DisconnectWindow{{StartSec: 60, DurationSec: 1}}})
+	if _, err := f.apply(context.Background()); err != nil {
+		t.Errorf("apply before the window = %v, want nil", err)
+	}
+}
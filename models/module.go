@@ -3,16 +3,24 @@ package models
 import (
 	"context"
 	"fmt"
+	"image"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.viam.com/rdk/components/camera"
 	"go.viam.com/rdk/components/camera/rtppassthrough"
-	"go.viam.com/rdk/gostream"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/pointcloud"
 	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage"
 	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/rdk/utils"
 	"go.viam.com/utils/rpc"
 	"gocv.io/x/gocv"
 
@@ -38,20 +46,76 @@ func init() {
 // Config holds the JSON attributes
 type Config struct {
 	// Local mode fields
-	VideoPath *string `json:"video_path,omitempty"`
-	FPS       *int    `json:"fps,omitempty"`
-	LoopVideo *bool   `json:"loop_video,omitempty"`
-	Height    *int    `json:"height,omitempty"`
-	Width     *int    `json:"width,omitempty"`
+	VideoPath *string  `json:"video_path,omitempty"`
+	FPS       *float64 `json:"fps,omitempty"` // fractional rates such as 29.97 are allowed
+	LoopVideo *bool    `json:"loop_video,omitempty"`
+	Height    *int     `json:"height,omitempty"`
+	Width     *int     `json:"width,omitempty"`
+	Fit       *string  `json:"fit,omitempty"` // "stretch" (default), "letterbox" or "crop" when scaling to width/height
+
+	// Output encoding; Image requests can override either through extra
+	JPEGQuality    *int `json:"jpeg_quality,omitempty"`    // 1-100, also used for WebP; unset keeps source JPEGs untouched
+	PNGCompression *int `json:"png_compression,omitempty"` // 0 (fastest) to 9 (smallest)
+
+	// How frame capture times are reported: "original" (default), "wallclock" or "offset"
+	TimestampMode  *string `json:"timestamp_mode,omitempty"`
+	TimestampStart *string `json:"timestamp_start,omitempty"` // RFC3339 instant the recording starts at in offset mode
+
+	// Cameras with the same sync_group share one playback clock and show frames by aligned capture time
+	SyncGroup *string `json:"sync_group,omitempty"`
+
+	// Load the source and serve its first frame, but start the timeline only on the "trigger" DoCommand
+	StartPaused *bool          `json:"start_paused,omitempty"`
+	Trigger     *TriggerConfig `json:"trigger,omitempty"` // also start when a dependency's DoCommand or reading reports ready
+
+	// Drop, delay and repeat frames in the update loop like a flaky camera
+	Faults *FaultConfig `json:"faults,omitempty"`
+	// Delay and fail Image/Images calls, or serve them stale frames
+	ImageFaults *ImageFaultConfig `json:"image_faults,omitempty"`
+	// Degrade decoded frames with noise, blur, exposure shifts, steam, smudges, occlusion and JPEG artifacts
+	Degradations *DegradationConfig `json:"degradations,omitempty"`
+
+	// Serve an MJPEG stream, the current frame and a status page on localhost for debugging
+	DebugHTTPPort *int `json:"debug_http_port,omitempty"`
+
+	// Camera calibration of the replayed source: Viam intrinsics JSON or OpenCV YAML
+	CalibrationPath *string `json:"calibration_path,omitempty"`
 
 	// Core dataset mode fields (simplified)
-	Mode           *string `json:"mode,omitempty"`            // "local" or "dataset"
+	Mode           *string `json:"mode,omitempty"`            // "local", "dataset" or "capture_history"
 	APIKey         *string `json:"api_key,omitempty"`         // Viam API key
 	APIKeyID       *string `json:"api_key_id,omitempty"`      // Viam API key ID
 	OrganizationID *string `json:"organization_id,omitempty"` // Organization ID
 	DatasetID      *string `json:"dataset_id,omitempty"`      // Dataset ID to replay from
+
+	// Capture history mode fields: replay what a part's component captured in a time window
+	PartID        *string `json:"part_id,omitempty"`        // Machine part that captured the data
+	ComponentName *string `json:"component_name,omitempty"` // Camera component that captured the data
+	StartTime     *string `json:"start_time,omitempty"`     // RFC3339 start of the capture window
+	EndTime       *string `json:"end_time,omitempty"`       // RFC3339 end of the capture window
+
+	// What to do when a dataset image can't be decoded: "skip", "placeholder" (default), "error" or "last_good"
+	OnDecodeError *string `json:"on_decode_error,omitempty"`
 }
 
+// captureHistoryMimeTypes restricts capture_history to the images a camera
+// captures, so other binaries of the component aren't fetched only to fail decoding
+var captureHistoryMimeTypes = []string{"image/jpeg", "image/png", "image/webp", "image/bmp", "image/tiff"}
+
+// Capture history images are downloaded this many at a time, each page within the timeout
+const (
+	captureHistoryPageSize     = 100
+	captureHistoryFetchTimeout = 30 * time.Second
+)
+
+// Decode failure policies for on_decode_error
+const (
+	decodeErrorSkip        = "skip"        // move on to the next decodable image
+	decodeErrorPlaceholder = "placeholder" // serve a synthetic colored frame
+	decodeErrorError       = "error"       // fail Image/Images until a good frame loads
+	decodeErrorLastGood    = "last_good"   // keep serving the previous good frame
+)
+
 // Validate ensures required fields are set based on mode
 func (c *Config) Validate(path string) ([]string, []string, error) {
 	// Determine mode (default to local if not specified)
@@ -66,23 +130,127 @@ func (c *Config) Validate(path string) ([]string, []string, error) {
 			return nil, nil, fmt.Errorf("video_path is required for local mode video replay camera")
 		}
 	case "dataset":
-		if c.APIKey == nil || *c.APIKey == "" {
-			return nil, nil, fmt.Errorf("api_key is required for dataset mode")
-		}
-		if c.APIKeyID == nil || *c.APIKeyID == "" {
-			return nil, nil, fmt.Errorf("api_key_id is required for dataset mode")
-		}
-		if c.OrganizationID == nil || *c.OrganizationID == "" {
-			return nil, nil, fmt.Errorf("organization_id is required for dataset mode")
+		if err := c.validateCredentials(mode); err != nil {
+			return nil, nil, err
 		}
 		if c.DatasetID == nil || *c.DatasetID == "" {
 			return nil, nil, fmt.Errorf("dataset_id is required for dataset mode")
 		}
+	case "capture_history":
+		if err := c.validateCredentials(mode); err != nil {
+			return nil, nil, err
+		}
+		if c.PartID == nil || *c.PartID == "" {
+			return nil, nil, fmt.Errorf("part_id is required for capture_history mode")
+		}
+		if c.ComponentName == nil || *c.ComponentName == "" {
+			return nil, nil, fmt.Errorf("component_name is required for capture_history mode")
+		}
+		if _, _, err := c.captureWindow(); err != nil {
+			return nil, nil, err
+		}
 	default:
-		return nil, nil, fmt.Errorf("invalid mode '%s': must be 'local' or 'dataset'", mode)
+		return nil, nil, fmt.Errorf("invalid mode '%s': must be 'local', 'dataset' or 'capture_history'", mode)
+	}
+
+	if err := c.validatePlayback(); err != nil {
+		return nil, nil, err
 	}
 
-	return nil, nil, nil
+	if c.OnDecodeError != nil {
+		switch *c.OnDecodeError {
+		case decodeErrorSkip, decodeErrorPlaceholder, decodeErrorError, decodeErrorLastGood:
+		default:
+			return nil, nil, fmt.Errorf("invalid on_decode_error '%s': must be 'skip', 'placeholder', 'error' or 'last_good'",
+				*c.OnDecodeError)
+		}
+	}
+
+	return c.dependencies(), nil, nil
+}
+
+// validatePlayback checks the playback and output fields shared by every source
+func (c *Config) validatePlayback() error {
+	if err := c.validateTimestampMode(); err != nil {
+		return err
+	}
+
+	if c.FPS != nil && *c.FPS <= 0 {
+		return fmt.Errorf("fps must be positive, got %v", *c.FPS)
+	}
+
+	if err := c.validateOutputSize(); err != nil {
+		return err
+	}
+
+	if _, err := c.encodeOptions().withOverrides(nil); err != nil {
+		return err
+	}
+
+	if c.DebugHTTPPort != nil && (*c.DebugHTTPPort < 1 || *c.DebugHTTPPort > 65535) {
+		return fmt.Errorf("debug_http_port must be between 1 and 65535, got %d", *c.DebugHTTPPort)
+	}
+
+	if c.Trigger != nil {
+		if err := c.Trigger.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Faults != nil {
+		if err := c.Faults.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.ImageFaults != nil {
+		if err := c.ImageFaults.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Degradations != nil {
+		if err := c.Degradations.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateCredentials checks the Viam API fields shared by the data-backed modes
+func (c *Config) validateCredentials(mode string) error {
+	if c.APIKey == nil || *c.APIKey == "" {
+		return fmt.Errorf("api_key is required for %s mode", mode)
+	}
+	if c.APIKeyID == nil || *c.APIKeyID == "" {
+		return fmt.Errorf("api_key_id is required for %s mode", mode)
+	}
+	if c.OrganizationID == nil || *c.OrganizationID == "" {
+		return fmt.Errorf("organization_id is required for %s mode", mode)
+	}
+	return nil
+}
+
+// captureWindow parses start_time and end_time for capture_history mode
+func (c *Config) captureWindow() (time.Time, time.Time, error) {
+	if c.StartTime == nil || *c.StartTime == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("start_time is required for capture_history mode")
+	}
+	if c.EndTime == nil || *c.EndTime == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("end_time is required for capture_history mode")
+	}
+	start, err := time.Parse(time.RFC3339, *c.StartTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start_time %q: must be RFC3339: %w", *c.StartTime, err)
+	}
+	end, err := time.Parse(time.RFC3339, *c.EndTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid end_time %q: must be RFC3339: %w", *c.EndTime, err)
+	}
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end_time %q must be after start_time %q", *c.EndTime, *c.StartTime)
+	}
+	return start, end, nil
 }
 
 // DatasetImage represents a cached image from a dataset
@@ -90,19 +258,65 @@ type DatasetImage struct {
 	Data      []byte
 	Timestamp time.Time
 	Filename  string
+	BinaryID  string
+}
+
+// DatasetVideo represents a video clip from a dataset, downloaded to temp storage
+type DatasetVideo struct {
+	Path      string
+	Timestamp time.Time
+	Filename  string
+	BinaryID  string
 }
 
-// DatasetReplay handles fetching and replaying images from Viam datasets
+// videoFile is one file in the local video playback list
+type videoFile struct {
+	path      string
+	name      string    // reported as the frame's source file
+	startTime time.Time // capture time of the first frame; zero means when playback reaches the file
+	binaryID  string
+
+	// Position on the playback timeline, set by probeTimeline for synced playback
+	offset   time.Duration
+	duration time.Duration
+	fps      float64
+}
+
+// videoExtensions lists file extensions treated as video clips rather than still images
+var videoExtensions = map[string]bool{
+	".mp4":  true,
+	".mov":  true,
+	".avi":  true,
+	".mkv":  true,
+	".m4v":  true,
+	".webm": true,
+}
+
+// DatasetReplay handles fetching and replaying images from Viam data, either a
+// curated dataset or a component's capture history
 type DatasetReplay struct {
 	logger         logging.Logger
 	apiKey         string
 	apiKeyID       string
 	organizationID string
 	datasetID      string
+	filter         app.Filter // selects the binary data to replay
 
 	images       []DatasetImage
+	videos       []DatasetVideo
+	tempDir      string // holds downloaded video clips; removed by cleanup
 	currentIndex int
 	mu           sync.RWMutex
+
+	// Capture history images are listed up front, but their bytes are
+	// downloaded a page at a time as playback reaches them, through
+	// viamClient, which stays open until cleanup
+	lazy       bool
+	viamClient *app.ViamClient
+
+	// Decode failure handling
+	decodePolicy   string
+	decodeFailures map[string]int // failure count per filename
 }
 
 // videoReplayVideo implements camera.Camera + resource.Reconfigurable
@@ -116,23 +330,97 @@ type videoReplayVideo struct {
 	// This is created in newVideoReplayVideo and ends when the resource is closed.
 	mainCtx context.Context
 
+	// lifecycleMu serializes Reconfigure, Close and the "arm" DoCommand, which
+	// all stop and restart the update loop, the capture and trigger polling
+	lifecycleMu sync.Mutex
+
 	// The loop context gets created (and canceled) each time we open a video,
 	// e.g. in Reconfigure or initially.
 	loopCtx    context.Context
 	loopCancel context.CancelFunc
-
-	// OpenCV capture (for local video mode)
+	loopWG     sync.WaitGroup
+
+	// OpenCV capture (for local video mode and dataset video clips).
+	// videoFiles are played back to back; videoIndex is the file currently open
+	// and fileStart the capture time its presentation timestamps count from.
+	// rawCapture is set when the capture returns undecoded Motion-JPEG packets.
+	// h264Capture reads the same H.264 file in raw mode, in step with
+	// videoCapture, for RTP passthrough; h264Gap is set when a grabbed frame's
+	// access unit was skipped, so the next frame sent must resync.
 	videoCapture *gocv.VideoCapture
-	fps          float64
-
-	// Current frame updated by background loop
-	frameMutex       sync.RWMutex
-	currentFrame     gocv.Mat
-	currentFrameTime time.Time
+	h264Capture  *gocv.VideoCapture
+	h264Gap      bool
+	videoFiles   []videoFile
+	videoIndex   int
+	fileStart    time.Time
+	// recordingStart is the capture time the current pass over the recording
+	// started at, and stampStart the instant timestamp_mode offset moves it to
+	recordingStart time.Time
+	stampStart     time.Time
+	// fileOffset is where the open file starts on the recording's timeline, and
+	// lastPosition where the last frame read sits on it
+	fileOffset   time.Duration
+	lastPosition time.Duration
+	filePts      float64 // presentation time of the last frame grabbed, in ms; -1 before the first
+	// droppedFrames counts frames skipped to catch up with the timeline
+	droppedFrames atomic.Uint64
+	// faults injects the configured faults into the running update loop, and
+	// imageFaults into Image/Images calls
+	faults      atomic.Pointer[faultInjector]
+	imageFaults atomic.Pointer[imageFaultInjector]
+	// degrader degrades decoded frames before they become current
+	degrader   atomic.Pointer[degrader]
+	rawCapture bool
+	fps        float64
+
+	// Current frame updated by background loop; nil until the first frame. The
+	// frame carries its own capture time and provenance. frameErr is set
+	// instead of a frame when the source can't produce one (on_decode_error:
+	// error). sourceWidth/sourceHeight are the frame's size before scaling.
+	// Every change is published to frame subscribers, in order, while
+	// frameMutex is held.
+	frameMutex   sync.RWMutex
+	current      *replayFrame
+	recent       []*replayFrame // previous frames, oldest first, kept for stale-frame serving
+	staleDepth   int            // how many previous frames recent keeps
+	frameErr     error
+	sourceWidth  int
+	sourceHeight int
+	frames       frameBus
+
+	// RTP subscriptions, fed by an RTP loop that runs while there are any
+	rtpMu     sync.Mutex
+	rtpSubs   map[rtppassthrough.SubscriptionID]*rtpSubscriber
+	rtpCancel context.CancelFunc
+	rtpWG     sync.WaitGroup
+
+	// Shared playback clock when in a sync_group
+	clock *playbackClock
+
+	// Optional debug HTTP server (debug_http_port); debugCancel ends its requests
+	debugServer *http.Server
+	debugCancel context.CancelFunc
+
+	// Optional calibration of the replayed source, reported by Properties
+	calibration *calibration
 
 	// Dataset replay fields
 	mode          string
 	datasetReplay *DatasetReplay
+
+	// Thermal frames when the camera is a thermal replay
+	thermal *thermalReplay
+
+	// Playback hold for start_paused and arm/trigger. While started is open,
+	// update loops wait on it before playing; triggeredAt is set when it is
+	// closed. triggerDep is polled for the configured trigger while armed,
+	// until triggerCancel. triggerMu guards started, triggeredAt and triggerCancel.
+	triggerMu     sync.Mutex
+	started       chan struct{}
+	triggeredAt   time.Time
+	triggerDep    resource.Resource
+	triggerCancel context.CancelFunc
+	triggerWG     sync.WaitGroup
 }
 
 // newVideoReplayVideo is called once when camera is created
@@ -155,63 +443,110 @@ func newVideoReplayVideo(
 		mode = *conf.Mode
 	}
 
-	// Create a context for the camera's lifetime
-	ctx, cancelFunc := context.WithCancel(context.Background())
-
-	cam := &videoReplayVideo{
-		name:       rawConf.ResourceName(),
-		logger:     logger,
-		cfg:        conf,
-		cancelFunc: cancelFunc,
-		mainCtx:    ctx,
-		mode:       mode,
-
-		// We'll open the video + start the loop below
-		currentFrame: gocv.NewMat(),
+	cam, err := newReplayCamera(rawConf.ResourceName(), deps, conf, mode, logger)
+	if err != nil {
+		return nil, err
 	}
 
 	// Initialize based on mode
 	switch mode {
 	case "local":
-		if err := cam.openAndStartLoop(*conf.VideoPath); err != nil {
+		if err := cam.openAndStartLoop(videoFile{path: *conf.VideoPath, name: *conf.VideoPath}); err != nil {
 			// If we fail to open, do cleanup
-			cancelFunc()
+			cam.Close(context.Background())
 			return nil, fmt.Errorf("failed to open camera at creation: %w", err)
 		}
-	case "dataset":
-		datasetReplay, err := newDatasetReplay(conf, logger)
+	case "dataset", "capture_history":
+		datasetReplay, err := newDatasetReplay(mode, conf, logger)
 		if err != nil {
-			cancelFunc()
+			cam.Close(context.Background())
 			return nil, fmt.Errorf("failed to initialize dataset replay: %w", err)
 		}
 		cam.datasetReplay = datasetReplay
 
 		if err := cam.initDatasetReplay(); err != nil {
-			cancelFunc()
+			cam.Close(context.Background())
 			return nil, fmt.Errorf("failed to initialize dataset replay: %w", err)
 		}
 	}
 
-	logger.Warnf("Camera %q: real-time streaming not implemented; SubscribeRTP calls will fail", cam.name)
+	if err := cam.startDebugServer(); err != nil {
+		cam.Close(context.Background())
+		return nil, err
+	}
+
 	logger.Infof("[newVideoReplayVideo] Camera constructed successfully: %q", cam.name)
 	return cam, nil
 }
 
-// openAndStartLoop is used by constructor + Reconfigure
-func (s *videoReplayVideo) openAndStartLoop(videoPath string) error {
-	// If a loop is running, cancel it
+// newReplayCamera sets up the parts of a replay camera shared by every source:
+// its lifetime context, calibration, sync group and start trigger
+func newReplayCamera(
+	name resource.Name,
+	deps resource.Dependencies,
+	conf *Config,
+	mode string,
+	logger logging.Logger,
+) (*videoReplayVideo, error) {
+	// Create a context for the camera's lifetime
+	ctx, cancelFunc := context.WithCancel(context.Background())
+
+	cam := &videoReplayVideo{
+		name:       name,
+		logger:     logger,
+		cfg:        conf,
+		cancelFunc: cancelFunc,
+		mainCtx:    ctx,
+		mode:       mode,
+	}
+
+	if conf.CalibrationPath != nil {
+		cal, err := loadCalibration(*conf.CalibrationPath)
+		if err != nil {
+			cancelFunc()
+			return nil, err
+		}
+		cam.calibration = cal
+	}
+
+	cam.updateSyncGroup()
+	cam.startImageFaults()
+	cam.startTimestamps()
+	cam.startDegradations()
+	if err := cam.resolveTrigger(deps); err != nil {
+		cam.Close(context.Background())
+		return nil, err
+	}
+	if conf.startPaused() {
+		cam.armTimeline()
+	}
+	return cam, nil
+}
+
+// stopLoop cancels the running update loop and waits for it to exit
+func (s *videoReplayVideo) stopLoop() {
 	if s.loopCancel != nil {
 		s.loopCancel()
 		s.loopCancel = nil
 	}
-	// Close existing capture if any
-	if s.videoCapture != nil {
-		s.videoCapture.Close()
-		s.videoCapture = nil
+	s.loopWG.Wait()
+}
+
+// openAndStartLoop is used by constructor + Reconfigure. When several files are
+// given they are played back to back, in order, as one continuous video.
+func (s *videoReplayVideo) openAndStartLoop(files ...videoFile) error {
+	if len(files) == 0 {
+		return fmt.Errorf("no video files to play")
 	}
+	videoPath := files[0].path
+
+	// If a loop is running, cancel it
+	s.stopLoop()
+	// Close existing capture if any
+	s.closeCapture()
 
 	// Open new file
-	cap, err := gocv.VideoCaptureFile(videoPath)
+	cap, raw, err := s.openCapture(videoPath)
 	if err != nil {
 		return fmt.Errorf("failed to open %q: %w", videoPath, err)
 	}
@@ -222,28 +557,24 @@ func (s *videoReplayVideo) openAndStartLoop(videoPath string) error {
 
 	// Override with configured FPS if provided
 	if s.cfg.FPS != nil {
-		fps = float64(*s.cfg.FPS)
-		s.logger.Infof("[openAndStartLoop] Overriding video FPS with configured value: %.2f", fps)
+		fps = *s.cfg.FPS
+		s.logger.Infof("[openAndStartLoop] Overriding video FPS with configured value: %.3f", fps)
 	}
 
-	// Read initial frame
-	firstFrame := gocv.NewMat()
-	if ok := cap.Read(&firstFrame); !ok || firstFrame.Empty() {
-		firstFrame.Close()
-		cap.Close()
+	// Read initial frame and store in struct
+	s.setCapture(cap, raw, videoPath)
+	s.videoFiles = files
+	s.videoIndex = 0
+	if s.clock != nil {
+		s.probeTimeline(files)
+		s.clock.setSourceStart(s, files[0].startTime)
+	}
+	s.startFile()
+	if !s.readFrame() {
+		s.closeCapture()
 		return fmt.Errorf("failed to read initial frame from %q", videoPath)
 	}
 
-	// Store in struct
-	s.frameMutex.Lock()
-	if !s.currentFrame.Empty() {
-		s.currentFrame.Close()
-	}
-	s.currentFrame = firstFrame
-	s.currentFrameTime = time.Now()
-	s.frameMutex.Unlock()
-
-	s.videoCapture = cap
 	s.fps = fps
 
 	// Start background loop with a fresh context from mainCtx
@@ -251,55 +582,177 @@ func (s *videoReplayVideo) openAndStartLoop(videoPath string) error {
 	s.loopCtx = loopCtx
 	s.loopCancel = loopCancel
 
-	s.logger.Infof("[openAndStartLoop] Opened %q (FPS=%.2f), starting loop...", videoPath, fps)
-	go s.frameUpdateLoop(loopCtx, fps)
+	s.logger.Infof("[openAndStartLoop] Opened %q (1 of %d files, FPS=%.2f), starting loop...",
+		videoPath, len(files), fps)
+	s.loopWG.Add(1)
+	go func() {
+		defer s.loopWG.Done()
+		if s.clock != nil {
+			s.syncedVideoLoop(loopCtx, fps)
+			return
+		}
+		if s.awaitTrigger(loopCtx) {
+			s.frameUpdateLoop(loopCtx, fps)
+		}
+	}()
 
 	return nil
 }
 
-// frameUpdateLoop updates currentFrame ~fps
+// startFile sets the capture time the current file's timestamps count from:
+// the recorded start time when known, otherwise now
+func (s *videoReplayVideo) startFile() {
+	s.fileStart = s.videoFiles[s.videoIndex].startTime
+	if s.fileStart.IsZero() {
+		s.fileStart = time.Now()
+	}
+	// Each pass over the files starts the recording over. Later files follow
+	// on from the previous one, or sit where the synced timeline puts them.
+	switch {
+	case s.videoIndex == 0:
+		s.recordingStart = s.fileStart
+		s.fileOffset = 0
+	case s.clock != nil:
+		s.fileOffset = s.videoFiles[s.videoIndex].offset
+	default:
+		s.fileOffset = s.lastPosition + frameInterval(s.fps)
+	}
+}
+
+// advanceVideo moves playback past the end of the current file: on to the next
+// file in videoFiles, or back to the start when looping. It returns false when
+// playback should stop.
+func (s *videoReplayVideo) advanceVideo(shouldLoop bool) bool {
+	next := s.videoIndex + 1
+	if next >= len(s.videoFiles) {
+		if !shouldLoop {
+			return false
+		}
+		next = 0
+	}
+
+	// A single file just rewinds; otherwise swap the capture for the next file
+	if next == s.videoIndex {
+		s.rewindCapture()
+		s.startFile()
+		return true
+	}
+	path := s.videoFiles[next].path
+	cap, raw, err := s.openCapture(path)
+	if err != nil {
+		s.logger.Errorf("[advanceVideo] Failed to open %q: %v", path, err)
+		return false
+	}
+	s.setCapture(cap, raw, path)
+	s.videoIndex = next
+	s.startFile()
+	s.logger.Infof("[advanceVideo] Playing %q (%d of %d) for %q",
+		path, next+1, len(s.videoFiles), s.name)
+	return true
+}
+
+// frameUpdateLoop shows each frame at its presentation time, counted from when
+// the file started playing. With a configured fps, frames are shown at that
+// rate instead. Frames that are already late when grabbed are skipped without
+// being decoded, so a slow decode doesn't stretch the timeline.
 func (s *videoReplayVideo) frameUpdateLoop(ctx context.Context, fps float64) {
-	s.logger.Infof("[frameUpdateLoop] Starting for camera %q at FPS=%.2f", s.name, fps)
-	ticker := time.NewTicker(time.Second / time.Duration(fps))
-	defer ticker.Stop()
+	s.logger.Infof("[frameUpdateLoop] Starting for camera %q at FPS=%.3f", s.name, fps)
+	faults := s.startFaults()
+	interval := frameInterval(fps)
+	retime := s.cfg.FPS != nil
+	catchUp := 2 * interval
+
+	// offset is when the grabbed frame is due, relative to the start of its file
+	offset := func() time.Duration {
+		if retime {
+			return time.Duration(s.frameIndex()) * interval
+		}
+		return time.Duration(s.filePts * float64(time.Millisecond))
+	}
 
+	anchor := time.Now().Add(-offset())
+	lastDue := time.Now()
+	reanchor := false
+	// emptyAdvances counts files moved to in a row without grabbing a frame
+	emptyAdvances := 0
 	for {
-		select {
-		case <-ctx.Done():
+		if ctx.Err() != nil {
 			s.logger.Infof("[frameUpdateLoop] canceled for %q", s.name)
 			return
-		case <-ticker.C:
-			newFrame := gocv.NewMat()
-			if ok := s.videoCapture.Read(&newFrame); !ok || newFrame.Empty() {
-				// Check if looping is enabled
-				shouldLoop := true // default to true for backward compatibility
-				if s.cfg.LoopVideo != nil {
-					shouldLoop = *s.cfg.LoopVideo
-				}
-
-				if shouldLoop {
-					s.logger.Infof("[frameUpdateLoop] End of file => reset to 0 for %q (loop enabled)", s.name)
-					s.videoCapture.Set(gocv.VideoCapturePosFrames, 0)
-					newFrame.Close()
-					newFrame = gocv.NewMat()
-					s.videoCapture.Read(&newFrame)
-				} else {
-					s.logger.Infof("[frameUpdateLoop] End of file => stopping playback for %q (loop disabled)", s.name)
-					newFrame.Close()
-					// Keep the last frame frozen instead of stopping completely
-					return
-				}
+		}
+		if !s.grabFrame() {
+			// A full pass over the files that yields no frame would otherwise spin forever
+			if emptyAdvances > len(s.videoFiles) {
+				s.logger.Errorf("[frameUpdateLoop] No frames left in any of the %d files => stopping playback for %q",
+					len(s.videoFiles), s.name)
+				return
+			}
+			emptyAdvances++
+
+			// Check if looping is enabled
+			shouldLoop := true // default to true for backward compatibility
+			if s.cfg.LoopVideo != nil {
+				shouldLoop = *s.cfg.LoopVideo
+			}
+
+			if !s.advanceVideo(shouldLoop) {
+				s.logger.Infof("[frameUpdateLoop] End of file => stopping playback for %q (loop disabled)", s.name)
+				// Keep the last frame frozen instead of stopping completely
+				return
+			}
+			s.logger.Infof("[frameUpdateLoop] End of file => continuing with file %d for %q (loop=%v)",
+				s.videoIndex+1, s.name, shouldLoop)
+			reanchor = true
+			continue
+		}
+
+		emptyAdvances = 0
+
+		// The next file starts one frame after the last frame of the previous one
+		if reanchor {
+			anchor = lastDue.Add(interval).Add(-offset())
+			reanchor = false
+		}
+		due := anchor.Add(offset())
+		wait := time.Until(due)
+		if wait < -catchUp {
+			s.skipFrame()
+			s.droppedFrames.Add(1)
+			continue
+		}
+		action := faults.next()
+		if action == faultDrop {
+			s.skipFrame()
+			lastDue = due
+			continue
+		}
+		wait += faults.jitter()
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				s.logger.Infof("[frameUpdateLoop] canceled for %q", s.name)
+				return
+			case <-time.After(wait):
 			}
-			now := time.Now()
-			s.frameMutex.Lock()
-			s.currentFrame.Close()
-			s.currentFrame = newFrame
-			s.currentFrameTime = now
-			s.frameMutex.Unlock()
+		} else if ctx.Err() != nil {
+			s.logger.Infof("[frameUpdateLoop] canceled for %q", s.name)
+			return
 		}
+		if action == faultDuplicate {
+			s.skipFrame()
+			s.duplicateFrame()
+		} else {
+			s.retrieveFrame()
+		}
+		lastDue = due
 	}
 }
 
+// frameInterval returns the time between frames at fps
+func frameInterval(fps float64) time.Duration {
+	return time.Duration(float64(time.Second) / fps)
+}
+
 // Reconfigure changes the video by always stopping and restarting the loop.
 func (s *videoReplayVideo) Reconfigure(
 	ctx context.Context,
@@ -307,6 +760,13 @@ func (s *videoReplayVideo) Reconfigure(
 	rawConf resource.Config,
 ) error {
 	s.logger.Infof("[Reconfigure] Called for %q", s.name)
+	s.lifecycleMu.Lock()
+	defer s.lifecycleMu.Unlock()
+
+	// Thermal replays have their own config type; rebuild them from scratch
+	if s.thermal != nil {
+		return resource.NewMustRebuildError(s.name)
+	}
 
 	newConf, err := resource.NativeConfig[*Config](rawConf)
 	if err != nil {
@@ -319,45 +779,69 @@ func (s *videoReplayVideo) Reconfigure(
 		newMode = *newConf.Mode
 	}
 
-	// If mode changed, we need to reinitialize everything
-	modeChanged := s.mode != newMode
-
 	// Always stop the running loop first
-	if s.loopCancel != nil {
-		s.loopCancel()
-		s.loopCancel = nil
-	}
+	s.stopLoop()
+	s.stopDebugServer()
+	s.disarm()
+
+	// Drop the old source's frames, so none of them is served stale afterwards
+	s.frameMutex.Lock()
+	s.releaseFrames()
+	s.frameMutex.Unlock()
 
-	// Clean up based on current mode
-	if s.mode == "local" && s.videoCapture != nil {
-		s.videoCapture.Close()
-		s.videoCapture = nil
+	// Clean up capture (local mode, or dataset video clips) and downloaded clips
+	s.closeCapture()
+	s.videoFiles = nil
+	if s.datasetReplay != nil {
+		s.datasetReplay.cleanup()
 	}
 
 	// Update configuration and mode
 	s.cfg = newConf
 	s.mode = newMode
+	s.updateSyncGroup()
+	s.startImageFaults()
+	s.startTimestamps()
+	s.startDegradations()
+	if err := s.resolveTrigger(deps); err != nil {
+		return fmt.Errorf("reconfigure: %w", err)
+	}
+	if newConf.startPaused() {
+		s.armTimeline()
+	}
+
+	s.calibration = nil
+	if newConf.CalibrationPath != nil {
+		cal, err := loadCalibration(*newConf.CalibrationPath)
+		if err != nil {
+			return fmt.Errorf("reconfigure: %w", err)
+		}
+		s.calibration = cal
+	}
 
 	// Initialize based on new mode
 	switch newMode {
 	case "local":
-		if err := s.openAndStartLoop(*newConf.VideoPath); err != nil {
+		if err := s.openAndStartLoop(videoFile{path: *newConf.VideoPath, name: *newConf.VideoPath}); err != nil {
 			return fmt.Errorf("reconfigure local mode: %w", err)
 		}
-	case "dataset":
-		if modeChanged || s.datasetReplay == nil {
-			datasetReplay, err := newDatasetReplay(newConf, s.logger)
-			if err != nil {
-				return fmt.Errorf("reconfigure dataset mode: failed to initialize dataset replay: %w", err)
-			}
-			s.datasetReplay = datasetReplay
+	case "dataset", "capture_history":
+		// Always rebuild so credential, dataset or time window changes take effect
+		datasetReplay, err := newDatasetReplay(newMode, newConf, s.logger)
+		if err != nil {
+			return fmt.Errorf("reconfigure %s mode: failed to initialize dataset replay: %w", newMode, err)
 		}
+		s.datasetReplay = datasetReplay
 
 		if err := s.initDatasetReplay(); err != nil {
-			return fmt.Errorf("reconfigure dataset mode: failed to initialize dataset replay: %w", err)
+			return fmt.Errorf("reconfigure %s mode: failed to initialize dataset replay: %w", newMode, err)
 		}
 	}
 
+	if err := s.startDebugServer(); err != nil {
+		return fmt.Errorf("reconfigure: %w", err)
+	}
+
 	s.logger.Infof("[Reconfigure] Successfully reconfigured to mode '%s'", newMode)
 	return nil
 }
@@ -367,53 +851,77 @@ func (s *videoReplayVideo) Name() resource.Name {
 	return s.name
 }
 
-// Image returns the latest frame as JPEG
+// Image returns the latest frame encoded in the requested MIME type, JPEG when
+// none is requested. Types the frame can't be encoded as are an error.
 func (s *videoReplayVideo) Image(
 	ctx context.Context,
 	mimeType string,
 	extra map[string]interface{},
 ) ([]byte, camera.ImageMetadata, error) {
-	s.logger.Infof("[Image] Called for camera %q, mimeType=%q", s.name, mimeType)
+	s.logger.Debugf("[Image] Called for camera %q, mimeType=%q", s.name, mimeType)
 
-	s.frameMutex.RLock()
-	defer s.frameMutex.RUnlock()
+	frame, err := s.acquireImageFrame(ctx)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
+	defer frame.release()
+
+	opts, err := s.cfg.encodeOptions().withOverrides(extra)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
 
-	if s.currentFrame.Empty() {
-		return nil, camera.ImageMetadata{}, fmt.Errorf("no frame available")
+	// Source JPEG bytes are served untouched unless a quality is set; other
+	// encodings are cached per frame and options
+	outType, err := resolveMimeType(mimeType, frame.isDepth())
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
 	}
-	buf, err := gocv.IMEncode(".jpg", s.currentFrame)
+	data, err := frame.Encode(ctx, outType, opts)
 	if err != nil {
-		return nil, camera.ImageMetadata{}, fmt.Errorf("encode fail: %w", err)
+		return nil, camera.ImageMetadata{}, fmt.Errorf("encode %s fail: %w", outType, err)
 	}
 
 	meta := camera.ImageMetadata{
-		MimeType: "image/jpeg",
+		MimeType: outType,
 	}
-	return buf.GetBytes(), meta, nil
+	return data, meta, nil
 }
 
-// Images returns one NamedImage
+// Images returns one NamedImage built straight from the current frame, with
+// the frame's capture time
 func (s *videoReplayVideo) Images(ctx context.Context) ([]camera.NamedImage, resource.ResponseMetadata, error) {
-	b, _, err := s.Image(ctx, "image/jpeg", nil)
+	frame, err := s.acquireImageFrame(ctx)
 	if err != nil {
 		return nil, resource.ResponseMetadata{}, err
 	}
-	mat, err := gocv.IMDecode(b, gocv.IMReadColor)
-	if err != nil {
-		return nil, resource.ResponseMetadata{}, fmt.Errorf("imdecode fail: %w", err)
-	}
-	defer mat.Close()
+	defer frame.release()
 
-	goImg, err := mat.ToImage()
-	if err != nil {
-		return nil, resource.ResponseMetadata{}, fmt.Errorf("mat.ToImage fail: %w", err)
+	// With a configured quality, color frames go out as JPEGs at that quality
+	// rather than whatever the camera server would encode them as
+	var goImg image.Image
+	if opts := s.cfg.encodeOptions(); opts.jpegQuality > 0 && !frame.isDepth() {
+		data, err := frame.Encode(ctx, utils.MimeTypeJPEG, opts)
+		if err != nil {
+			return nil, resource.ResponseMetadata{}, fmt.Errorf("encode %s fail: %w", utils.MimeTypeJPEG, err)
+		}
+		goImg = rimage.NewLazyEncodedImage(data, utils.MimeTypeJPEG)
+	} else {
+		goImg, err = frame.Image()
+		if err != nil {
+			return nil, resource.ResponseMetadata{}, fmt.Errorf("frame to image fail: %w", err)
+		}
 	}
 
+	sourceName := "color"
+	if frame.isDepth() {
+		sourceName = "depth"
+	}
 	named := []camera.NamedImage{{
 		Image:      goImg,
-		SourceName: "color",
+		SourceName: sourceName,
 	}}
-	return named, resource.ResponseMetadata{}, nil
+	return named, resource.ResponseMetadata{CapturedAt: frame.meta.capturedAt}, nil
 }
 
 // NextPointCloud is not supported
@@ -421,63 +929,121 @@ func (s *videoReplayVideo) NextPointCloud(ctx context.Context) (pointcloud.Point
 	return nil, fmt.Errorf("pointcloud not supported")
 }
 
-// Properties returns minimal info
+// Properties returns the dimensions of the frames actually served and, when a
+// calibration file is configured, intrinsics and distortion scaled to match
 func (s *videoReplayVideo) Properties(ctx context.Context) (camera.Properties, error) {
-	return camera.Properties{
+	s.frameMutex.RLock()
+	var width, height int
+	depth := false
+	if s.current != nil {
+		width, height = s.current.width, s.current.height
+		depth = s.current.isDepth()
+	}
+	srcWidth, srcHeight := s.sourceWidth, s.sourceHeight
+	s.frameMutex.RUnlock()
+	mimeTypes := supportedMimeTypes(depth)
+
+	props := camera.Properties{
 		SupportsPCD: false,
 		ImageType:   camera.ColorStream,
 		IntrinsicParams: &transform.PinholeCameraIntrinsics{
-			Width:  640,
-			Height: 480,
+			Width:  width,
+			Height: height,
 		},
-		MimeTypes: []string{"image/jpeg"},
-	}, nil
+		MimeTypes: mimeTypes,
+	}
+	if s.calibration != nil {
+		props.IntrinsicParams = s.calibration.scaledIntrinsics(s.cfg, srcWidth, srcHeight)
+		if s.calibration.distortion != nil {
+			props.DistortionParams = s.calibration.distortion
+		}
+	}
+	return props, nil
 }
 
-// DoCommand is not supported
+// DoCommand dispatches on cmd["command"]:
+//   - "decode_errors": dataset decode failures, total and per filename
+//   - "frame_info": capture time and provenance of the current frame
+//   - "temperatures": the temperatures behind the current thermal frame
+//   - "arm": restart at the first frame and hold there until "trigger"
+//   - "trigger": start the held timeline
+//   - "play", "pause", "seek" (position_ms), "set_speed" (speed), "clock":
+//     control and report the sync group's shared clock
 func (s *videoReplayVideo) DoCommand(
 	ctx context.Context,
 	cmd map[string]interface{},
 ) (map[string]interface{}, error) {
-	return nil, fmt.Errorf("do command not supported")
+	name, _ := cmd["command"].(string)
+	switch name {
+	case "decode_errors":
+		if s.datasetReplay == nil {
+			return map[string]interface{}{"total": 0, "files": map[string]interface{}{}}, nil
+		}
+		return s.datasetReplay.decodeErrorReport(), nil
+	case "play", "pause", "seek", "set_speed", "clock":
+		return s.syncCommand(name, cmd)
+	case "frame_info":
+		frame, err := s.acquireFrame()
+		if err != nil {
+			return nil, err
+		}
+		defer frame.release()
+		info := frame.meta.provenance()
+		info["mode"] = s.mode
+		info["timestamp_mode"] = s.cfg.timestampMode()
+		info["dropped_frames"] = s.droppedFrames.Load()
+		info["armed"] = s.armed()
+		if stats := s.faultStats(); stats != nil {
+			info["faults"] = stats
+		}
+		if stats := s.imageFaultStats(); stats != nil {
+			info["image_faults"] = stats
+		}
+		if stats := s.degradationStats(); stats != nil {
+			info["degradations"] = stats
+		}
+		return info, nil
+	case "temperatures":
+		return s.temperaturesCommand()
+	case "arm", "trigger":
+		return s.triggerCommand(name)
+	default:
+		return nil, fmt.Errorf("unknown command %q", name)
+	}
 }
 
 // Close cleans up on resource removal
 func (s *videoReplayVideo) Close(ctx context.Context) error {
 	s.logger.Infof("[Close] Called for %q", s.name)
-	// stop loop
-	if s.loopCancel != nil {
-		s.loopCancel()
+	s.lifecycleMu.Lock()
+	defer s.lifecycleMu.Unlock()
+	// stop loop, trigger polling and debug server, and leave the sync group
+	s.stopLoop()
+	s.stopTriggerWatch()
+	s.stopDebugServer()
+	if s.clock != nil {
+		s.clock.leave(s)
+		s.clock = nil
 	}
+	// terminate RTP subscriptions
+	s.closeRTP()
 	// close capture
-	if s.videoCapture != nil {
-		s.videoCapture.Close()
+	s.closeCapture()
+	// remove downloaded dataset clips
+	if s.datasetReplay != nil {
+		s.datasetReplay.cleanup()
 	}
-	// free last frame
+	// end frame subscriptions and free last frame
+	s.frames.close()
 	s.frameMutex.Lock()
-	s.currentFrame.Close()
+	s.releaseFrames()
 	s.frameMutex.Unlock()
+	s.degrader.Swap(nil).close()
 	// end main resource context
 	s.cancelFunc()
 	return nil
 }
 
-// The streaming methods
-func (s *videoReplayVideo) SubscribeRTP(
-	ctx context.Context,
-	bufferSize int,
-	packetsCB rtppassthrough.PacketCallback,
-) (rtppassthrough.Subscription, error) {
-	return rtppassthrough.Subscription{}, fmt.Errorf("streaming not implemented")
-}
-
-func (s *videoReplayVideo) Unsubscribe(
-	ctx context.Context,
-	id rtppassthrough.SubscriptionID,
-) error {
-	return fmt.Errorf("streaming not implemented")
-}
-
 // If you need a remote client approach:
 func (s *videoReplayVideo) NewClientFromConn(
 	ctx context.Context,
@@ -489,21 +1055,41 @@ func (s *videoReplayVideo) NewClientFromConn(
 	return nil, fmt.Errorf("not implemented")
 }
 
-func (s *videoReplayVideo) Stream(
-	ctx context.Context,
-	errHandlers ...gostream.ErrorHandler,
-) (gostream.VideoStream, error) {
-	return nil, fmt.Errorf("not implemented")
-}
-
-// newDatasetReplay creates a new DatasetReplay instance
-func newDatasetReplay(conf *Config, logger logging.Logger) (*DatasetReplay, error) {
+// newDatasetReplay creates a new DatasetReplay instance for dataset or capture_history mode
+func newDatasetReplay(mode string, conf *Config, logger logging.Logger) (*DatasetReplay, error) {
 	dr := &DatasetReplay{
 		logger:         logger,
 		apiKey:         *conf.APIKey,
 		apiKeyID:       *conf.APIKeyID,
 		organizationID: *conf.OrganizationID,
-		datasetID:      *conf.DatasetID,
+		decodePolicy:   decodeErrorPlaceholder,
+		decodeFailures: map[string]int{},
+	}
+	if conf.OnDecodeError != nil {
+		dr.decodePolicy = *conf.OnDecodeError
+	}
+
+	switch mode {
+	case "dataset":
+		dr.datasetID = *conf.DatasetID
+		dr.filter = app.Filter{
+			DatasetID: dr.datasetID,
+		}
+	case "capture_history":
+		start, end, err := conf.captureWindow()
+		if err != nil {
+			return nil, err
+		}
+		dr.filter = app.Filter{
+			OrganizationIDs: []string{dr.organizationID},
+			PartID:          *conf.PartID,
+			ComponentName:   *conf.ComponentName,
+			MimeType:        captureHistoryMimeTypes,
+			Interval:        app.CaptureInterval{Start: start, End: end},
+		}
+		dr.lazy = true
+	default:
+		return nil, fmt.Errorf("mode %q does not replay Viam data", mode)
 	}
 
 	return dr, nil
@@ -515,7 +1101,30 @@ func (s *videoReplayVideo) initDatasetReplay() error {
 		return fmt.Errorf("failed to fetch images from dataset: %w", err)
 	}
 
-	// Start the dataset replay loop
+	// Video clips go through the same pipeline as local video files
+	if len(s.datasetReplay.videos) > 0 {
+		if len(s.datasetReplay.images) > 0 {
+			s.logger.Warnf("[initDatasetReplay] Dataset has %d video clips and %d still images; replaying clips only",
+				len(s.datasetReplay.videos), len(s.datasetReplay.images))
+		}
+		files := make([]videoFile, 0, len(s.datasetReplay.videos))
+		for _, v := range s.datasetReplay.videos {
+			files = append(files, videoFile{path: v.Path, name: v.Filename, startTime: v.Timestamp, binaryID: v.BinaryID})
+		}
+		s.logger.Infof("[initDatasetReplay] Replaying %d dataset video clips in capture order", len(files))
+		return s.openAndStartLoop(files...)
+	}
+
+	return s.startDatasetLoop()
+}
+
+// startDatasetLoop starts cycling through the dataset images from the current
+// one. When armed, the first image is shown right away and the loop waits for
+// the trigger.
+func (s *videoReplayVideo) startDatasetLoop() error {
+	if len(s.datasetReplay.images) == 0 {
+		return fmt.Errorf("no dataset images to replay")
+	}
 	loopCtx, loopCancel := context.WithCancel(s.mainCtx)
 	s.loopCtx = loopCtx
 	s.loopCancel = loopCancel
@@ -523,21 +1132,49 @@ func (s *videoReplayVideo) initDatasetReplay() error {
 	// Calculate FPS based on dataset or use default
 	fps := 30.0
 	if s.cfg.FPS != nil {
-		fps = float64(*s.cfg.FPS)
+		fps = *s.cfg.FPS
 	}
 	s.fps = fps
 
 	s.logger.Infof("[initDatasetReplay] Starting dataset replay loop with %d images at FPS=%.2f",
 		len(s.datasetReplay.images), fps)
-	go s.datasetReplayLoop(loopCtx, fps)
+	s.recordingStart = s.datasetReplay.images[0].Timestamp
+	if s.clock != nil {
+		s.clock.setSourceStart(s, s.recordingStart)
+	} else if s.armed() {
+		if err := s.datasetReplay.loadNextFrame(s); err != nil {
+			s.logger.Errorf("[startDatasetLoop] Failed to load first frame: %v", err)
+		}
+	}
+	s.loopWG.Add(1)
+	go func() {
+		defer s.loopWG.Done()
+		if s.clock != nil {
+			s.syncedDatasetLoop(loopCtx, fps)
+			return
+		}
+		if s.awaitTrigger(loopCtx) {
+			s.datasetReplayLoop(loopCtx, fps)
+		}
+	}()
 
 	return nil
 }
 
+// skipFrame moves past the next image without loading it
+func (dr *DatasetReplay) skipFrame() {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+	if len(dr.images) > 0 {
+		dr.currentIndex = (dr.currentIndex + 1) % len(dr.images)
+	}
+}
+
 // datasetReplayLoop cycles through dataset images at the specified FPS
 func (s *videoReplayVideo) datasetReplayLoop(ctx context.Context, fps float64) {
-	s.logger.Infof("[datasetReplayLoop] Starting for camera %q at FPS=%.2f", s.name, fps)
-	ticker := time.NewTicker(time.Second / time.Duration(fps))
+	s.logger.Infof("[datasetReplayLoop] Starting for camera %q at FPS=%.3f", s.name, fps)
+	faults := s.startFaults()
+	ticker := time.NewTicker(frameInterval(fps))
 	defer ticker.Stop()
 
 	for {
@@ -546,6 +1183,19 @@ func (s *videoReplayVideo) datasetReplayLoop(ctx context.Context, fps float64) {
 			s.logger.Infof("[datasetReplayLoop] canceled for %q", s.name)
 			return
 		case <-ticker.C:
+			switch faults.next() {
+			case faultDrop:
+				s.datasetReplay.skipFrame()
+				continue
+			case faultDuplicate:
+				s.datasetReplay.skipFrame()
+				s.duplicateFrame()
+				continue
+			}
+			if !sleepCtx(ctx, faults.jitter()) {
+				s.logger.Infof("[datasetReplayLoop] canceled for %q", s.name)
+				return
+			}
 			if err := s.datasetReplay.loadNextFrame(s); err != nil {
 				s.logger.Errorf("[datasetReplayLoop] Failed to load next frame: %v", err)
 			}
@@ -553,9 +1203,12 @@ func (s *videoReplayVideo) datasetReplayLoop(ctx context.Context, fps float64) {
 	}
 }
 
-// fetchImages retrieves images from the Viam dataset
+// fetchImages retrieves images and video clips matching the replay's filter
 func (dr *DatasetReplay) fetchImages() error {
-	dr.logger.Info("Fetching images from Viam dataset...")
+	dr.logger.Info("Fetching images from Viam data...")
+
+	// Drop clips from any previous fetch
+	dr.cleanup()
 
 	ctx := context.Background()
 
@@ -564,30 +1217,47 @@ func (dr *DatasetReplay) fetchImages() error {
 	if err != nil {
 		return fmt.Errorf("failed to create Viam client: %v", err)
 	}
-	defer viamClient.Close()
+
+	if dr.lazy {
+		dr.viamClient = viamClient
+	} else {
+		defer viamClient.Close()
+	}
 
 	dataClient := viamClient.DataClient()
 
-	// Create filter for dataset
-	filter := &app.Filter{
-		DatasetID: dr.datasetID,
+	// Fetch binary data page by page; capture windows easily exceed one page.
+	// Lazy replays only list the binaries here and download them later.
+	filter := dr.filter
+	var binaries []*app.BinaryData
+	last := ""
+	for {
+		resp, err := dataClient.BinaryDataByFilter(ctx, !dr.lazy, &app.DataByFilterOptions{
+			Filter:    &filter,
+			Limit:     100,
+			Last:      last,
+			SortOrder: app.Ascending,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to fetch dataset images: %v", err)
+		}
+		binaries = append(binaries, resp.BinaryData...)
+		if len(resp.BinaryData) == 0 || resp.Last == "" || resp.Last == last {
+			break
+		}
+		last = resp.Last
 	}
 
-	// Fetch binary data from dataset
-	resp, err := dataClient.BinaryDataByFilter(ctx, true, &app.DataByFilterOptions{
-		Filter: filter,
-		Limit:  100, // Start with reasonable limit
-	})
-	if err != nil {
-		return fmt.Errorf("failed to fetch dataset images: %v", err)
+	dr.logger.Infof("Found %d binaries matching filter", len(binaries))
+	if len(binaries) == 0 {
+		return fmt.Errorf("no data found for filter")
 	}
 
-	dr.logger.Infof("Found %d images in dataset", len(resp.BinaryData))
-
-	// Convert binary data to DatasetImage objects
-	dr.images = make([]DatasetImage, 0, len(resp.BinaryData))
-	for i, binaryData := range resp.BinaryData {
-		if binaryData.Binary == nil {
+	// Convert binary data to DatasetImage / DatasetVideo objects
+	dr.images = make([]DatasetImage, 0, len(binaries))
+	dr.videos = nil
+	for i, binaryData := range binaries {
+		if binaryData.Binary == nil && (!dr.lazy || binaryDataID(binaryData.Metadata) == "") {
 			dr.logger.Warnf("Skipping image %d with no binary data", i)
 			continue
 		}
@@ -606,20 +1276,112 @@ func (dr *DatasetReplay) fetchImages() error {
 			filename = binaryData.Metadata.FileName
 		}
 
+		if isVideoBinary(binaryData.Metadata) {
+			video, err := dr.saveVideo(i, filename, binaryData)
+			if err != nil {
+				return err
+			}
+			video.Timestamp = timestamp
+			video.BinaryID = binaryDataID(binaryData.Metadata)
+			dr.videos = append(dr.videos, video)
+			continue
+		}
+
 		datasetImage := DatasetImage{
 			Data:      binaryData.Binary,
 			Timestamp: timestamp,
 			Filename:  filename,
+			BinaryID:  binaryDataID(binaryData.Metadata),
 		}
 
 		dr.images = append(dr.images, datasetImage)
 	}
 
-	dr.logger.Infof("Successfully loaded %d images from dataset", len(dr.images))
+	if len(dr.images) == 0 && len(dr.videos) == 0 {
+		return fmt.Errorf("none of the %d binaries matching the filter had data to replay", len(binaries))
+	}
+
+	// Replay in capture order
+	sort.SliceStable(dr.images, func(i, j int) bool { return dr.images[i].Timestamp.Before(dr.images[j].Timestamp) })
+	sort.SliceStable(dr.videos, func(i, j int) bool { return dr.videos[i].Timestamp.Before(dr.videos[j].Timestamp) })
+
+	dr.logger.Infof("Successfully loaded %d images and %d video clips", len(dr.images), len(dr.videos))
 	return nil
 }
 
-// loadNextFrame loads the next frame from the dataset into the camera
+// binaryDataID returns the ID that identifies a binary data entry in Viam
+func binaryDataID(md *app.BinaryMetadata) string {
+	if md == nil {
+		return ""
+	}
+	if md.BinaryDataID != "" {
+		return md.BinaryDataID
+	}
+	return md.ID
+}
+
+// isVideoBinary reports whether a dataset binary is a video clip, based on its
+// MIME type or file extension
+func isVideoBinary(md *app.BinaryMetadata) bool {
+	if md == nil {
+		return false
+	}
+	if strings.HasPrefix(md.CaptureMetadata.MimeType, "video/") {
+		return true
+	}
+	ext := md.FileExt
+	if ext == "" {
+		ext = filepath.Ext(md.FileName)
+	}
+	return videoExtensions[strings.ToLower(ext)]
+}
+
+// saveVideo writes a video binary to the replay's temp directory so OpenCV can open it
+func (dr *DatasetReplay) saveVideo(index int, filename string, binaryData *app.BinaryData) (DatasetVideo, error) {
+	if dr.tempDir == "" {
+		dir, err := os.MkdirTemp("", "video-replay-*")
+		if err != nil {
+			return DatasetVideo{}, fmt.Errorf("failed to create temp dir for dataset videos: %w", err)
+		}
+		dr.tempDir = dir
+	}
+
+	ext := binaryData.Metadata.FileExt
+	if ext == "" {
+		ext = filepath.Ext(filename)
+	}
+	if ext == "" {
+		ext = ".mp4"
+	}
+	base := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	path := filepath.Join(dr.tempDir, fmt.Sprintf("%04d_%s%s", index, base, ext))
+	if err := os.WriteFile(path, binaryData.Binary, 0o600); err != nil {
+		return DatasetVideo{}, fmt.Errorf("failed to save dataset video %s: %w", filename, err)
+	}
+
+	dr.logger.Debugf("Saved dataset video %s to %s", filename, path)
+	return DatasetVideo{Path: path, Filename: filename}, nil
+}
+
+// cleanup closes a lazy replay's data client and removes any video clips
+// downloaded to temp storage
+func (dr *DatasetReplay) cleanup() {
+	if dr.viamClient != nil {
+		dr.viamClient.Close()
+		dr.viamClient = nil
+	}
+	if dr.tempDir == "" {
+		return
+	}
+	if err := os.RemoveAll(dr.tempDir); err != nil {
+		dr.logger.Warnf("Failed to remove dataset video temp dir %s: %v", dr.tempDir, err)
+	}
+	dr.tempDir = ""
+	dr.videos = nil
+}
+
+// loadNextFrame loads the next frame from the dataset into the camera, applying
+// the decode failure policy to images that can't be decoded
 func (dr *DatasetReplay) loadNextFrame(cam *videoReplayVideo) error {
 	dr.mu.Lock()
 	defer dr.mu.Unlock()
@@ -628,39 +1390,151 @@ func (dr *DatasetReplay) loadNextFrame(cam *videoReplayVideo) error {
 		return fmt.Errorf("no images available")
 	}
 
-	// Get current image
-	currentImage := dr.images[dr.currentIndex]
+	// With "skip", try each image at most once per tick before giving up
+	attempts := 1
+	if dr.decodePolicy == decodeErrorSkip {
+		attempts = len(dr.images)
+	}
 
-	// Convert image data to gocv.Mat
-	// Decode the image bytes directly (JPEG/PNG/etc) into a proper image matrix
-	newFrame, err := gocv.IMDecode(currentImage.Data, gocv.IMReadColor)
-	if err != nil || newFrame.Empty() {
-		// If decoding fails (e.g., with test data), create a colored placeholder frame
-		dr.logger.Warnf("Failed to decode image data for %s, using placeholder: %v", currentImage.Filename, err)
-		newFrame = gocv.NewMatWithSize(480, 640, gocv.MatTypeCV8UC3)
+	for attempts > 0 {
+		// Get current image and move to next frame (loop back to start if at end)
+		index := dr.currentIndex
+		if dr.lazy && dr.images[index].Data == nil {
+			// Download without holding mu, so decode reports and status aren't
+			// held up by the network
+			ids, client := dr.pageIDs(index), dr.viamClient
+			if client == nil {
+				return fmt.Errorf("capture history client is closed")
+			}
+			dr.mu.Unlock()
+			data, err := fetchPage(client, ids)
+			dr.mu.Lock()
+			if err != nil {
+				return fmt.Errorf("failed to fetch capture history images %d to %d: %w", index, index+len(ids)-1, err)
+			}
+			dr.installPage(index, ids, data)
+			if len(dr.images) == 0 {
+				return fmt.Errorf("no images available")
+			}
+			continue
+		}
+		attempts--
+		currentImage := dr.images[index]
+		dr.currentIndex = (dr.currentIndex + 1) % len(dr.images)
+
+		// JPEGs pass straight through; other formats are decoded (JPEG/PNG/etc)
+		// into a proper image matrix
+		meta := frameMeta{
+			capturedAt: currentImage.Timestamp,
+			file:       currentImage.Filename,
+			frameIndex: index,
+			binaryID:   currentImage.BinaryID,
+			position:   currentImage.Timestamp.Sub(dr.images[0].Timestamp),
+		}
+		err := cam.setSourceBytes(currentImage.Data, meta)
+		if err == nil {
+			dr.logger.Debugf("Loaded frame %d: %s", index, currentImage.Filename)
+			return nil
+		}
+		dr.decodeFailures[currentImage.Filename]++
 
-		// Fill with a color based on frame index for visual distinction
-		color := gocv.NewScalar(
-			float64((dr.currentIndex*50)%255),  // Blue
-			float64((dr.currentIndex*100)%255), // Green
-			float64((dr.currentIndex*150)%255), // Red
-			0,                                  // Alpha
-		)
-		newFrame.SetTo(color)
+		switch dr.decodePolicy {
+		case decodeErrorSkip:
+			dr.logger.Warnf("Failed to decode image data for %s, skipping: %v", currentImage.Filename, err)
+			continue
+		case decodeErrorLastGood:
+			dr.logger.Warnf("Failed to decode image data for %s, keeping last good frame: %v", currentImage.Filename, err)
+			return nil
+		case decodeErrorError:
+			err = fmt.Errorf("failed to decode dataset image %s: %w", currentImage.Filename, err)
+			cam.setFrameError(err)
+			return err
+		default:
+			dr.logger.Warnf("Failed to decode image data for %s, using placeholder: %v", currentImage.Filename, err)
+			cam.setFrame(placeholderFrame(index), meta)
+			return nil
+		}
 	}
 
-	// Update camera's current frame
-	cam.frameMutex.Lock()
-	if !cam.currentFrame.Empty() {
-		cam.currentFrame.Close()
+	return fmt.Errorf("none of the %d dataset images could be decoded", len(dr.images))
+}
+
+// pageIDs returns the binary IDs of the page of images starting at index.
+// Callers must hold mu.
+func (dr *DatasetReplay) pageIDs(index int) []string {
+	end := min(index+captureHistoryPageSize, len(dr.images))
+	ids := make([]string, 0, end-index)
+	for _, img := range dr.images[index:end] {
+		ids = append(ids, img.BinaryID)
 	}
-	cam.currentFrame = newFrame
-	cam.currentFrameTime = currentImage.Timestamp
-	cam.frameMutex.Unlock()
+	return ids
+}
 
-	// Move to next frame (loop back to start if at end)
-	dr.currentIndex = (dr.currentIndex + 1) % len(dr.images)
+// fetchPage downloads the bytes of the images with ids, keyed by binary ID.
+// It waits on the network, so callers must not hold the replay's mu.
+func fetchPage(client *app.ViamClient, ids []string) (map[string][]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), captureHistoryFetchTimeout)
+	defer cancel()
+	binaries, err := client.DataClient().BinaryDataByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string][]byte, len(binaries))
+	for _, b := range binaries {
+		data[binaryDataID(b.Metadata)] = b.Binary
+	}
+	return data, nil
+}
 
-	dr.logger.Debugf("Loaded frame %d: %s", dr.currentIndex, currentImage.Filename)
-	return nil
+// installPage stores a page fetched for the images starting at index and
+// drops the bytes of every other image, so a lazy replay holds one page at a
+// time. Images missing from data get empty bytes, which fail to decode under
+// on_decode_error rather than being fetched again and again; images that
+// changed while the page was fetched are left to fetch again. Callers must
+// hold mu.
+func (dr *DatasetReplay) installPage(index int, ids []string, data map[string][]byte) {
+	for i := range dr.images {
+		dr.images[i].Data = nil
+		if i >= index && i-index < len(ids) && dr.images[i].BinaryID == ids[i-index] {
+			b := data[ids[i-index]]
+			if b == nil {
+				b = []byte{}
+			}
+			dr.images[i].Data = b
+		}
+	}
+	dr.logger.Debugf("Fetched capture history images %d to %d", index, index+len(ids)-1)
+}
+
+// placeholderFrame creates a colored frame standing in for an undecodable image
+func placeholderFrame(index int) gocv.Mat {
+	frame := gocv.NewMatWithSize(480, 640, gocv.MatTypeCV8UC3)
+
+	// Fill with a color based on frame index for visual distinction
+	color := gocv.NewScalar(
+		float64((index*50)%255),  // Blue
+		float64((index*100)%255), // Green
+		float64((index*150)%255), // Red
+		0,                        // Alpha
+	)
+	frame.SetTo(color)
+	return frame
+}
+
+// decodeErrorReport summarizes decode failures for DoCommand
+func (dr *DatasetReplay) decodeErrorReport() map[string]interface{} {
+	dr.mu.RLock()
+	defer dr.mu.RUnlock()
+
+	total := 0
+	files := make(map[string]interface{}, len(dr.decodeFailures))
+	for filename, count := range dr.decodeFailures {
+		files[filename] = count
+		total += count
+	}
+	return map[string]interface{}{
+		"policy": dr.decodePolicy,
+		"total":  total,
+		"files":  files,
+	}
 }
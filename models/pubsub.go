@@ -0,0 +1,169 @@
+package models
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// dropPolicy decides what a subscriber loses when its channel is full
+type dropPolicy int
+
+const (
+	dropOldest dropPolicy = iota // discard the oldest queued frame to make room; consumers see the latest
+	dropNewest                   // discard the incoming frame; consumers see every frame up to the backlog
+)
+
+// frameEvent is one change of the camera's current frame: either a new frame,
+// retained for the receiver, or the error that replaced it
+type frameEvent struct {
+	frame  *replayFrame
+	err    error
+	repeat bool // frame was published before, as a duplicated frame
+}
+
+// release drops the event's frame reference, if any
+func (ev frameEvent) release() {
+	if ev.frame != nil {
+		ev.frame.release()
+	}
+}
+
+// frameSubscription receives every frame change on a bounded channel. The
+// receiver must release each frame it takes off C. C is closed when the
+// subscription ends.
+type frameSubscription struct {
+	C       <-chan frameEvent
+	ch      chan frameEvent
+	policy  dropPolicy
+	dropped atomic.Uint64
+}
+
+// Dropped returns the number of frames this subscriber lost to a full channel
+func (sub *frameSubscription) Dropped() uint64 {
+	return sub.dropped.Load()
+}
+
+// frameBus fans frame changes out to subscribers. Publishing never blocks:
+// a subscriber that falls behind loses frames according to its drop policy.
+type frameBus struct {
+	mu     sync.Mutex
+	subs   map[*frameSubscription]struct{}
+	closed bool
+}
+
+// subscribe registers a subscriber with a channel of the given size
+func (b *frameBus) subscribe(size int, policy dropPolicy) *frameSubscription {
+	ch := make(chan frameEvent, max(1, size))
+	sub := &frameSubscription{C: ch, ch: ch, policy: policy}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return sub
+	}
+	if b.subs == nil {
+		b.subs = map[*frameSubscription]struct{}{}
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// unsubscribe removes a subscriber, releases anything still queued for it and
+// closes its channel
+func (b *frameBus) unsubscribe(sub *frameSubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+	delete(b.subs, sub)
+	b.closeSubscription(sub)
+}
+
+// closeSubscription drains and closes a subscriber's channel. The caller must
+// hold mu.
+func (b *frameBus) closeSubscription(sub *frameSubscription) {
+drain:
+	for {
+		select {
+		case ev := <-sub.ch:
+			ev.release()
+		default:
+			break drain
+		}
+	}
+	close(sub.ch)
+}
+
+// publish delivers ev to every subscriber, retaining the frame once per
+// delivery. The caller keeps its own reference.
+func (b *frameBus) publish(ev frameEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		b.deliver(sub, ev)
+	}
+}
+
+// deliver queues ev for one subscriber, applying its drop policy when the
+// channel is full. The caller must hold mu.
+func (b *frameBus) deliver(sub *frameSubscription, ev frameEvent) {
+	if ev.frame != nil {
+		ev.frame.retain()
+	}
+	for {
+		select {
+		case sub.ch <- ev:
+			return
+		default:
+		}
+
+		if sub.policy == dropNewest {
+			sub.dropped.Add(1)
+			ev.release()
+			return
+		}
+		// Make room by discarding the oldest queued event; the receiver may
+		// have emptied the channel in the meantime, so just retry the send
+		select {
+		case old := <-sub.ch:
+			old.release()
+			sub.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+// close ends every subscription; later subscriptions are closed immediately
+func (b *frameBus) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subs {
+		b.closeSubscription(sub)
+	}
+	b.subs = nil
+}
+
+// subscribeFrames subscribes to the camera's frame changes. The current frame
+// (or frame error), if there is one, is queued right away so the subscriber
+// doesn't wait for the next change.
+func (s *videoReplayVideo) subscribeFrames(size int, policy dropPolicy) *frameSubscription {
+	s.frameMutex.Lock()
+	defer s.frameMutex.Unlock()
+	sub := s.frames.subscribe(size, policy)
+
+	s.frames.mu.Lock()
+	defer s.frames.mu.Unlock()
+	if _, ok := s.frames.subs[sub]; !ok {
+		return sub
+	}
+	switch {
+	case s.frameErr != nil:
+		s.frames.deliver(sub, frameEvent{err: s.frameErr})
+	case s.current != nil:
+		s.frames.deliver(sub, frameEvent{frame: s.current})
+	}
+	return sub
+}
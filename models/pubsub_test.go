@@ -0,0 +1,136 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"go.viam.com/rdk/utils"
+)
+
+// testFrames returns n frames told apart by their width
+func testFrames(n int) []*replayFrame {
+	frames := make([]*replayFrame, n)
+	for i := range frames {
+		frames[i] = newEncodedFrame([]byte{0xFF, 0xD8}, utils.MimeTypeJPEG, i, 1)
+	}
+	return frames
+}
+
+// drain takes every queued event off sub, releasing their frames, and returns
+// the widths of the frames received
+func drain(sub *frameSubscription) []int {
+	var widths []int
+	for {
+		select {
+		case ev, ok := <-sub.C:
+			if !ok {
+				return widths
+			}
+			if ev.frame != nil {
+				widths = append(widths, ev.frame.width)
+			}
+			ev.release()
+		default:
+			return widths
+		}
+	}
+}
+
+func TestFrameBusDropPolicies(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        int
+		policy      dropPolicy
+		published   int
+		want        []int
+		wantDropped uint64
+	}{
+		{name: "oldest room to spare", size: 4, policy: dropOldest, published: 3, want: []int{0, 1, 2}},
+		{name: "oldest keeps the latest", size: 2, policy: dropOldest, published: 5, want: []int{3, 4}, wantDropped: 3},
+		{name: "newest keeps the backlog", size: 2, policy: dropNewest, published: 5, want: []int{0, 1}, wantDropped: 3},
+		{name: "size rounded up to one", size: 0, policy: dropOldest, published: 3, want: []int{2}, wantDropped: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bus frameBus
+			sub := bus.subscribe(tt.size, tt.policy)
+			frames := testFrames(tt.published)
+			for _, f := range frames {
+				bus.publish(frameEvent{frame: f})
+			}
+
+			got := drain(sub)
+			if len(got) != len(tt.want) {
+				t.Fatalf("received frames %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("received frames %v, want %v", got, tt.want)
+				}
+			}
+			if n := sub.Dropped(); n != tt.wantDropped {
+				t.Errorf("Dropped() = %d, want %d", n, tt.wantDropped)
+			}
+			// Only the publisher's own reference is left once the queue is drained
+			for i, f := range frames {
+				if refs := f.refs.Load(); refs != 1 {
+					t.Errorf("frame %d has %d references, want 1", i, refs)
+				}
+			}
+			bus.unsubscribe(sub)
+		})
+	}
+}
+
+func TestFrameBusSubscribers(t *testing.T) {
+	var bus frameBus
+	latest := bus.subscribe(1, dropOldest)
+	backlog := bus.subscribe(3, dropNewest)
+	frames := testFrames(3)
+	for _, f := range frames {
+		bus.publish(frameEvent{frame: f})
+	}
+	errFrame := errors.New("decode failed")
+	bus.publish(frameEvent{err: errFrame})
+
+	// Each subscriber loses frames independently
+	select {
+	case ev := <-latest.C:
+		if !errors.Is(ev.err, errFrame) {
+			t.Errorf("latest subscriber got %+v, want the error", ev)
+		}
+	default:
+		t.Fatal("latest subscriber has nothing queued")
+	}
+	if got := latest.Dropped(); got != 3 {
+		t.Errorf("latest subscriber dropped %d, want 3", got)
+	}
+	if got := backlog.Dropped(); got != 1 {
+		t.Errorf("backlog subscriber dropped %d, want 1", got)
+	}
+
+	// Unsubscribing releases what is still queued and closes the channel
+	bus.unsubscribe(backlog)
+	for i, f := range frames {
+		if refs := f.refs.Load(); refs != 1 {
+			t.Errorf("frame %d has %d references after unsubscribe, want 1", i, refs)
+		}
+	}
+	if _, ok := <-backlog.C; ok {
+		t.Error("unsubscribed channel is still open")
+	}
+	bus.unsubscribe(backlog)
+
+	bus.close()
+	if _, ok := <-latest.C; ok {
+		t.Error("channel is still open after close")
+	}
+	late := bus.subscribe(1, dropOldest)
+	if _, ok := <-late.C; ok {
+		t.Error("subscription after close is open")
+	}
+	bus.publish(frameEvent{frame: frames[0]})
+	if refs := frames[0].refs.Load(); refs != 1 {
+		t.Errorf("publishing to a closed bus retained the frame: %d references", refs)
+	}
+}
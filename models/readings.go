@@ -0,0 +1,166 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+// ReadingsReplay is the sensor model replaying recorded readings
+var ReadingsReplay = resource.NewModel("bill", "sensor", "readings-replay")
+
+func init() {
+	resource.RegisterComponent(
+		sensor.API,
+		ReadingsReplay,
+		resource.Registration[sensor.Sensor, *ReadingsReplayConfig]{
+			Constructor: newReadingsReplay,
+		},
+	)
+}
+
+// ReadingsReplayConfig holds the JSON attributes
+type ReadingsReplayConfig struct {
+	ReadingsPath   *string `json:"readings_path,omitempty"`   // .jsonl/.ndjson readings or Viam tabular export, or .csv with a header row
+	ResourceName   *string `json:"resource_name,omitempty"`   // only replay this resource's rows of a tabular export
+	TimestampField *string `json:"timestamp_field,omitempty"` // key or column holding capture times; well-known names by default
+	Loop           *bool   `json:"loop,omitempty"`            // start over after the last reading (default true)
+	SyncGroup      *string `json:"sync_group,omitempty"`      // share a playback clock with replay cameras
+}
+
+// Validate ensures the readings file is set
+func (c *ReadingsReplayConfig) Validate(path string) ([]string, []string, error) {
+	if c.ReadingsPath == nil || *c.ReadingsPath == "" {
+		return nil, nil, fmt.Errorf("readings_path is required for the readings replay sensor")
+	}
+	return nil, nil, nil
+}
+
+// readingRecord is one recorded Readings result
+type readingRecord struct {
+	capturedAt time.Time
+	readings   map[string]interface{}
+}
+
+// readingsReplay serves, on each Readings call, the recorded reading at its
+// playback clock's position. The clock is the sync group's when configured, so
+// readings stay in step with replayed video; otherwise it is the sensor's own.
+type readingsReplay struct {
+	resource.Named
+	resource.AlwaysRebuild
+
+	logger  logging.Logger
+	path    string
+	records []readingRecord // in capture order
+	loop    bool
+	clock   *playbackClock
+}
+
+// newReadingsReplay loads the recorded readings and starts playing them
+func newReadingsReplay(
+	ctx context.Context,
+	deps resource.Dependencies,
+	rawConf resource.Config,
+	logger logging.Logger,
+) (sensor.Sensor, error) {
+	conf, err := resource.NativeConfig[*ReadingsReplayConfig](rawConf)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := readingsFileOptions{}
+	if conf.ResourceName != nil {
+		opts.resourceName = *conf.ResourceName
+	}
+	if conf.TimestampField != nil {
+		opts.timestampField = *conf.TimestampField
+	}
+	records, err := readReadingsFile(*conf.ReadingsPath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &readingsReplay{
+		Named:   rawConf.ResourceName().AsNamed(),
+		logger:  logger,
+		path:    *conf.ReadingsPath,
+		records: records,
+		loop:    true,
+	}
+	if conf.Loop != nil {
+		r.loop = *conf.Loop
+	}
+
+	if conf.SyncGroup != nil && *conf.SyncGroup != "" {
+		r.clock = joinSyncGroup(*conf.SyncGroup, r)
+		logger.Infof("[newReadingsReplay] Sensor %q joined sync group %q", r.Name(), *conf.SyncGroup)
+	} else {
+		r.clock = newPlaybackClock("")
+		r.clock.join(r)
+	}
+	r.clock.setSourceStart(r, records[0].capturedAt)
+
+	logger.Infof("[newReadingsReplay] Replaying %d readings from %q (%s to %s)", len(records), r.path,
+		records[0].capturedAt.UTC().Format(time.RFC3339), records[len(records)-1].capturedAt.UTC().Format(time.RFC3339))
+	return r, nil
+}
+
+// Readings returns a copy of the reading recorded at the clock's position
+func (r *readingsReplay) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	rec := r.records[r.indexAt(r.clock.sourcePosition(r))]
+	readings := make(map[string]interface{}, len(rec.readings))
+	for k, v := range rec.readings {
+		readings[k] = v
+	}
+	return readings, nil
+}
+
+// indexAt returns the last reading captured at or before position t since the
+// first one. When looping, the recording repeats after one average interval
+// past its last reading; otherwise the last reading is held.
+func (r *readingsReplay) indexAt(t time.Duration) int {
+	origin := r.records[0].capturedAt
+	last := r.records[len(r.records)-1].capturedAt.Sub(origin)
+	t = max(0, t)
+	if t > last {
+		if !r.loop || last == 0 {
+			return len(r.records) - 1
+		}
+		t %= last + last/time.Duration(len(r.records)-1)
+	}
+	index := sort.Search(len(r.records), func(i int) bool { return r.records[i].capturedAt.Sub(origin) > t }) - 1
+	return max(0, index)
+}
+
+// DoCommand dispatches on cmd["command"]:
+//   - "play", "pause", "seek" (position_ms), "set_speed" (speed), "clock":
+//     control and report the playback clock, shared with the sync group if any
+//   - "reading_info": capture time and index of the current reading
+func (r *readingsReplay) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	name, _ := cmd["command"].(string)
+	switch name {
+	case "play", "pause", "seek", "set_speed", "clock":
+		return r.clock.command(name, cmd)
+	case "reading_info":
+		index := r.indexAt(r.clock.sourcePosition(r))
+		return map[string]interface{}{
+			"captured_at": r.records[index].capturedAt.UTC().Format(time.RFC3339Nano),
+			"index":       index,
+			"count":       len(r.records),
+			"file":        r.path,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown command %q", name)
+	}
+}
+
+// Close leaves the sync group
+func (r *readingsReplay) Close(ctx context.Context) error {
+	r.clock.leave(r)
+	return nil
+}
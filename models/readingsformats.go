@@ -0,0 +1,283 @@
+package models
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// readingsTimestampFields are the keys capture times are looked for under when
+// timestamp_field isn't set, covering Viam tabular exports and common loggers
+var readingsTimestampFields = []string{
+	"timeCaptured", "time_captured",
+	"timeReceived", "time_received",
+	"timeRequested", "time_requested",
+	"timestamp", "time",
+}
+
+// readingsResourceFields are the keys a row names its resource under
+var readingsResourceFields = []string{"resourceName", "resource_name", "component_name"}
+
+// readingsColumnPrefixes are stripped from CSV column names, so flattened
+// tabular exports produce the reading names the sensor returned
+var readingsColumnPrefixes = []string{"payload.readings.", "data.readings.", "readings."}
+
+// readingsFileOptions select what is read from a readings file
+type readingsFileOptions struct {
+	resourceName   string // keep only rows of this resource, when rows name one
+	timestampField string // key or column holding capture times; readingsTimestampFields if empty
+}
+
+// readReadingsFile reads recorded readings from a CSV file or a file of JSON
+// lines, sorted by capture time
+func readReadingsFile(path string, opts readingsFileOptions) ([]readingRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open readings_path: %w", err)
+	}
+	defer file.Close()
+
+	var records []readingRecord
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		records, err = readReadingsCSV(file, opts)
+	} else {
+		records, err = readReadingsJSONL(file, opts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read readings from %q: %w", path, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no readings found in %q", path)
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].capturedAt.Before(records[j].capturedAt) })
+	if len(records) > 1 && records[len(records)-1].capturedAt.Equal(records[0].capturedAt) {
+		return nil, fmt.Errorf("all %d readings in %q have the same capture time; the recording has no length to replay", len(records), path)
+	}
+	return records, nil
+}
+
+// readReadingsJSONL reads one JSON object per line: a Viam tabular export row
+// (readings under payload.readings), a tabular data row (data.readings), an
+// object with a readings key, or the readings themselves next to a timestamp
+func readReadingsJSONL(r io.Reader, opts readingsFileOptions) ([]readingRecord, error) {
+	var records []readingRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var row map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &row); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if !opts.matchesResource(row) {
+			continue
+		}
+
+		field, raw := opts.findTimestamp(row)
+		if field == "" {
+			return nil, fmt.Errorf("line %d: no capture time found", line)
+		}
+		capturedAt, err := parseReadingTime(raw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		readings := nestedReadings(row)
+		if readings == nil {
+			readings = make(map[string]interface{}, len(row))
+			for k, v := range row {
+				if k != field && !slices.Contains(readingsResourceFields, k) {
+					readings[k] = v
+				}
+			}
+		}
+		records = append(records, readingRecord{capturedAt: capturedAt, readings: readings})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// matchesResource reports whether row belongs to the configured resource. Rows
+// that don't name a resource always match.
+func (o readingsFileOptions) matchesResource(row map[string]interface{}) bool {
+	if o.resourceName == "" {
+		return true
+	}
+	for _, key := range readingsResourceFields {
+		if name, ok := row[key].(string); ok {
+			return name == o.resourceName
+		}
+	}
+	return true
+}
+
+// findTimestamp returns the key and value of row's capture time, looking in
+// the row's metadata too; the key is empty if there is none
+func (o readingsFileOptions) findTimestamp(row map[string]interface{}) (string, interface{}) {
+	fields := readingsTimestampFields
+	if o.timestampField != "" {
+		fields = []string{o.timestampField}
+	}
+	for _, field := range fields {
+		if v, ok := row[field]; ok {
+			return field, v
+		}
+	}
+	if md, ok := row["metadata"].(map[string]interface{}); ok {
+		for _, field := range fields {
+			if v, ok := md[field]; ok {
+				return field, v
+			}
+		}
+	}
+	return "", nil
+}
+
+// nestedReadings returns the readings of export and tabular data rows, or nil
+// when the row is a flat record
+func nestedReadings(row map[string]interface{}) map[string]interface{} {
+	for _, key := range []string{"payload", "data"} {
+		if inner, ok := row[key].(map[string]interface{}); ok {
+			if readings, ok := inner["readings"].(map[string]interface{}); ok {
+				return readings
+			}
+		}
+	}
+	if readings, ok := row["readings"].(map[string]interface{}); ok {
+		return readings
+	}
+	return nil
+}
+
+// readReadingsCSV reads a CSV file with a header row. One column holds the
+// capture time; every other non-empty cell is a reading, as a number or bool
+// where it parses as one and a string otherwise.
+func readReadingsCSV(r io.Reader, opts readingsFileOptions) ([]readingRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	timeColumn, resourceColumn := -1, -1
+	names := make([]string, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		names[i] = name
+		if timeColumn < 0 && isTimestampColumn(name, opts.timestampField) {
+			timeColumn = i
+		}
+		if slices.Contains(readingsResourceFields, name) {
+			resourceColumn = i
+		}
+		for _, prefix := range readingsColumnPrefixes {
+			if strings.HasPrefix(name, prefix) {
+				names[i] = strings.TrimPrefix(name, prefix)
+				break
+			}
+		}
+	}
+	if timeColumn < 0 {
+		return nil, fmt.Errorf("no capture time column in header %v", header)
+	}
+
+	var records []readingRecord
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if timeColumn >= len(row) {
+			return nil, fmt.Errorf("line %d: missing capture time", line)
+		}
+		if opts.resourceName != "" && resourceColumn >= 0 && resourceColumn < len(row) &&
+			row[resourceColumn] != opts.resourceName {
+			continue
+		}
+		capturedAt, err := parseReadingTime(row[timeColumn])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		readings := map[string]interface{}{}
+		for i, cell := range row {
+			if i == timeColumn || i == resourceColumn || i >= len(names) || cell == "" {
+				continue
+			}
+			readings[names[i]] = parseReadingCell(cell)
+		}
+		records = append(records, readingRecord{capturedAt: capturedAt, readings: readings})
+	}
+	return records, nil
+}
+
+// isTimestampColumn reports whether a CSV column holds capture times
+func isTimestampColumn(name, field string) bool {
+	if field != "" {
+		return name == field
+	}
+	for _, f := range readingsTimestampFields {
+		if name == f {
+			return true
+		}
+	}
+	return false
+}
+
+// parseReadingCell converts a CSV cell to a number or bool when it is one
+func parseReadingCell(cell string) interface{} {
+	if v, err := strconv.ParseFloat(cell, 64); err == nil {
+		return v
+	}
+	if v, err := strconv.ParseBool(cell); err == nil {
+		return v
+	}
+	return cell
+}
+
+// parseReadingTime parses a capture time: an RFC3339 string, or Unix time in
+// seconds (milliseconds for values too large to be seconds)
+func parseReadingTime(v interface{}) (time.Time, error) {
+	var secs float64
+	switch v := v.(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, nil
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid capture time %q: must be RFC3339 or Unix seconds", v)
+		}
+		secs = f
+	case float64:
+		secs = v
+	default:
+		return time.Time{}, fmt.Errorf("invalid capture time %v", v)
+	}
+	if secs > 1e11 {
+		secs /= 1000
+	}
+	whole, frac := math.Modf(secs)
+	return time.Unix(int64(whole), int64(frac*1e9)), nil
+}
@@ -0,0 +1,238 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadReadingsJSONL(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		data    string
+		opts    readingsFileOptions
+		want    []readingRecord
+		wantErr string
+	}{
+		{
+			name: "tabular export",
+			data: `{"timeCaptured": "2024-05-01T12:00:00Z", "payload": {"readings": {"temp": 21.5}}}`,
+			want: []readingRecord{{capturedAt: t0, readings: map[string]interface{}{"temp": 21.5}}},
+		},
+		{
+			name: "tabular data row",
+			data: `{"metadata": {"time_captured": "2024-05-01T12:00:00Z"}, "data": {"readings": {"ok": true}}}`,
+			want: []readingRecord{{capturedAt: t0, readings: map[string]interface{}{"ok": true}}},
+		},
+		{
+			name: "readings key",
+			data: `{"time": 1714564800, "readings": {"a": 1}}`,
+			want: []readingRecord{{capturedAt: t0, readings: map[string]interface{}{"a": 1.0}}},
+		},
+		{
+			name: "flat record",
+			data: "{\"timestamp\": 1714564800000, \"a\": 1, \"b\": \"x\"}\n\n",
+			want: []readingRecord{{capturedAt: t0, readings: map[string]interface{}{"a": 1.0, "b": "x"}}},
+		},
+		{
+			name: "configured timestamp field",
+			data: `{"at": "2024-05-01T12:00:00Z", "time": "not used", "a": 1}`,
+			opts: readingsFileOptions{timestampField: "at"},
+			want: []readingRecord{{capturedAt: t0, readings: map[string]interface{}{"time": "not used", "a": 1.0}}},
+		},
+		{
+			name: "other resources skipped",
+			data: `{"resourceName": "other", "time": 1, "a": 1}
+{"resourceName": "mine", "time": 1714564800, "a": 2}`,
+			opts: readingsFileOptions{resourceName: "mine"},
+			want: []readingRecord{{capturedAt: t0, readings: map[string]interface{}{"a": 2.0}}},
+		},
+		{
+			name:    "no capture time",
+			data:    `{"a": 1}`,
+			wantErr: "line 1: no capture time found",
+		},
+		{
+			name:    "bad json",
+			data:    "{\"time\": 1}\n{\"time\": ",
+			wantErr: "line 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readReadingsJSONL(strings.NewReader(tt.data), tt.opts)
+			checkReadingRecords(t, got, err, tt.want, tt.wantErr)
+		})
+	}
+}
+
+func TestReadReadingsCSV(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		data    string
+		opts    readingsFileOptions
+		want    []readingRecord
+		wantErr string
+	}{
+		{
+			name: "flattened export",
+			data: "time_captured,resource_name,payload.readings.temp,payload.readings.ok,payload.readings.state\n" +
+				"2024-05-01T12:00:00Z,probe,21.5,true,idle\n",
+			want: []readingRecord{{capturedAt: t0, readings: map[string]interface{}{"temp": 21.5, "ok": true, "state": "idle"}}},
+		},
+		{
+			name: "empty cells omitted",
+			data: "time, readings.a, b\n1714564800,,2\n",
+			want: []readingRecord{{capturedAt: t0, readings: map[string]interface{}{"b": 2.0}}},
+		},
+		{
+			name: "other resources skipped",
+			data: "time,resourceName,a\n1,other,1\n1714564800,mine,2\n",
+			opts: readingsFileOptions{resourceName: "mine"},
+			want: []readingRecord{{capturedAt: t0, readings: map[string]interface{}{"a": 2.0}}},
+		},
+		{
+			name: "configured timestamp column",
+			data: "at,time\n2024-05-01T12:00:00Z,late\n",
+			opts: readingsFileOptions{timestampField: "at"},
+			want: []readingRecord{{capturedAt: t0, readings: map[string]interface{}{"time": "late"}}},
+		},
+		{
+			name:    "no time column",
+			data:    "a,b\n1,2\n",
+			wantErr: "no capture time column",
+		},
+		{
+			name:    "short row",
+			data:    "a,time\n1\n",
+			wantErr: "line 2: missing capture time",
+		},
+		{
+			name:    "bad time",
+			data:    "time,a\nyesterday,1\n",
+			wantErr: "line 2: invalid capture time",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readReadingsCSV(strings.NewReader(tt.data), tt.opts)
+			checkReadingRecords(t, got, err, tt.want, tt.wantErr)
+		})
+	}
+}
+
+func TestParseReadingTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       interface{}
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "RFC3339", v: "2024-05-01T12:00:00.25Z", want: time.Date(2024, 5, 1, 12, 0, 0, 250e6, time.UTC)},
+		{name: "seconds", v: 1714564800.5, want: time.Unix(1714564800, 500e6)},
+		{name: "seconds string", v: "1714564800", want: time.Unix(1714564800, 0)},
+		{name: "milliseconds", v: 1714564800500.0, want: time.Unix(1714564800, 500e6)},
+		{name: "milliseconds string", v: "1714564800000", want: time.Unix(1714564800, 0)},
+		{name: "largest seconds", v: 1e11, want: time.Unix(1e11, 0)},
+		{name: "smallest milliseconds", v: 1e11 + 1000, want: time.Unix(1e8+1, 0)},
+		{name: "not a time", v: "noon", wantErr: true},
+		{name: "wrong type", v: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseReadingTime(tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadReadingsFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		data    string
+		want    []time.Time
+		wantErr string
+	}{
+		{
+			name: "sorted by capture time",
+			file: "readings.jsonl",
+			data: "{\"time\": 20, \"a\": 2}\n{\"time\": 10, \"a\": 1}\n",
+			want: []time.Time{time.Unix(10, 0), time.Unix(20, 0)},
+		},
+		{
+			name: "csv by extension",
+			file: "readings.CSV",
+			data: "time,a\n10,1\n",
+			want: []time.Time{time.Unix(10, 0)},
+		},
+		{
+			name:    "empty",
+			file:    "readings.jsonl",
+			data:    "\n",
+			wantErr: "no readings found",
+		},
+		{
+			name:    "same capture time",
+			file:    "readings.jsonl",
+			data:    "{\"time\": 10, \"a\": 1}\n{\"time\": 10, \"a\": 2}\n",
+			wantErr: "same capture time",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readReadingsFile(writeTemp(t, tt.file, []byte(tt.data)), readingsFileOptions{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d records, want %d", len(got), len(tt.want))
+			}
+			for i, r := range got {
+				if !r.capturedAt.Equal(tt.want[i]) {
+					t.Errorf("record %d captured at %v, want %v", i, r.capturedAt, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// checkReadingRecords compares the result of a readings reader against the
+// wanted records or error
+func checkReadingRecords(t *testing.T, got []readingRecord, err error, want []readingRecord, wantErr string) {
+	t.Helper()
+	if wantErr != "" {
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Fatalf("err = %v, want one containing %q", err, wantErr)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if !got[i].capturedAt.Equal(want[i].capturedAt) {
+			t.Errorf("record %d captured at %v, want %v", i, got[i].capturedAt, want[i].capturedAt)
+		}
+		if !reflect.DeepEqual(got[i].readings, want[i].readings) {
+			t.Errorf("record %d readings = %v, want %v", i, got[i].readings, want[i].readings)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/generic"
+)
+
+// ReplayClock is a generic service reporting a sync group's replay time
+var ReplayClock = resource.NewModel("bill", "generic", "replay-clock")
+
+func init() {
+	resource.RegisterService(
+		generic.API,
+		ReplayClock,
+		resource.Registration[resource.Resource, *ReplayClockConfig]{
+			Constructor: newReplayClock,
+		},
+	)
+}
+
+// ReplayClockConfig holds the JSON attributes
+type ReplayClockConfig struct {
+	SyncGroup string `json:"sync_group"` // sync group of the replay cameras whose clock is reported
+}
+
+// Validate ensures the sync group is set
+func (c *ReplayClockConfig) Validate(path string) ([]string, []string, error) {
+	if c.SyncGroup == "" {
+		return nil, nil, fmt.Errorf("sync_group is required for the replay clock service")
+	}
+	return nil, nil, nil
+}
+
+// replayClock lets other modules read, and control, the playback clock of a
+// sync group, so their timers can run on replay time instead of wall time.
+// Its replay cameras must run in the same module process.
+type replayClock struct {
+	resource.Named
+	resource.AlwaysRebuild
+	resource.TriviallyCloseable
+
+	logger    logging.Logger
+	syncGroup string
+}
+
+func newReplayClock(
+	ctx context.Context,
+	deps resource.Dependencies,
+	rawConf resource.Config,
+	logger logging.Logger,
+) (resource.Resource, error) {
+	conf, err := resource.NativeConfig[*ReplayClockConfig](rawConf)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("[newReplayClock] Reporting sync group %q as %q", conf.SyncGroup, rawConf.ResourceName())
+	return &replayClock{
+		Named:     rawConf.ResourceName().AsNamed(),
+		logger:    logger,
+		syncGroup: conf.SyncGroup,
+	}, nil
+}
+
+// DoCommand dispatches on cmd["command"], "clock" by default:
+//   - "clock": replay_time (when the sources' capture times are known),
+//     position_ms, speed, paused and members of the sync group
+//   - "play", "pause", "seek" (position_ms), "set_speed" (speed): control the
+//     clock, as on the cameras
+//
+// Every response also has wall_time, the module's current wall clock time.
+func (rc *replayClock) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	name, _ := cmd["command"].(string)
+	if name == "" {
+		name = "clock"
+	}
+	clock, ok := lookupSyncGroup(rc.syncGroup)
+	if !ok {
+		return nil, fmt.Errorf("sync group %q has no replay cameras in this module", rc.syncGroup)
+	}
+	resp, err := clock.command(name, cmd)
+	if err != nil {
+		return nil, err
+	}
+	resp["wall_time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	return resp, nil
+}
@@ -0,0 +1,327 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"go.viam.com/rdk/components/camera/rtppassthrough"
+	"go.viam.com/rdk/gostream/codec"
+	"go.viam.com/rdk/gostream/codec/x264"
+	"go.viam.com/rdk/logging"
+	"gocv.io/x/gocv"
+)
+
+// RTP settings for the H.264 track
+const (
+	rtpMTU         = 1200
+	rtpPayloadType = 96
+	rtpClockRate   = 90000
+	rtpFrameQueue  = 30
+)
+
+// h264FourCCs are the codec tags H.264 files are stored under
+var h264FourCCs = map[string]bool{
+	"avc1": true,
+	"AVC1": true,
+	"h264": true,
+	"H264": true,
+	"x264": true,
+	"X264": true,
+}
+
+// rtpSubscriber is one SubscribeRTP caller. Its packetizer and state are only
+// touched by the RTP loop.
+type rtpSubscriber struct {
+	buf        *rtppassthrough.Buffer
+	callback   rtppassthrough.PacketCallback
+	packetizer rtp.Packetizer
+	started    bool // a keyframe has been sent, so the decoder can follow
+	lastSent   time.Time
+}
+
+// SubscribeRTP starts sending the replay to packetsCB as H.264 RTP packets.
+// H.264 sources are passed through as-is; anything else is encoded with x264.
+// Every subscriber starts at the next keyframe.
+func (s *videoReplayVideo) SubscribeRTP(
+	ctx context.Context,
+	bufferSize int,
+	packetsCB rtppassthrough.PacketCallback,
+) (rtppassthrough.Subscription, error) {
+	s.rtpMu.Lock()
+	defer s.rtpMu.Unlock()
+	if s.mainCtx.Err() != nil {
+		return rtppassthrough.NilSubscription, fmt.Errorf("camera %q is closed", s.name)
+	}
+
+	sub, buf, err := rtppassthrough.NewSubscription(bufferSize)
+	if err != nil {
+		return rtppassthrough.NilSubscription, err
+	}
+	buf.Start()
+	subscriber := &rtpSubscriber{
+		buf:      buf,
+		callback: packetsCB,
+		packetizer: rtp.NewPacketizer(rtpMTU, rtpPayloadType, rand.Uint32(),
+			&codecs.H264Payloader{}, rtp.NewRandomSequencer(), rtpClockRate),
+	}
+
+	if s.rtpSubs == nil {
+		s.rtpSubs = map[rtppassthrough.SubscriptionID]*rtpSubscriber{}
+	}
+	if len(s.rtpSubs) == 0 {
+		loopCtx, cancel := context.WithCancel(s.mainCtx)
+		s.rtpCancel = cancel
+		s.rtpWG.Add(1)
+		go func() {
+			defer s.rtpWG.Done()
+			s.rtpLoop(loopCtx)
+		}()
+	}
+	s.rtpSubs[sub.ID] = subscriber
+	s.logger.Infof("[SubscribeRTP] Added subscription %s for %q (%d active)", sub.ID, s.name, len(s.rtpSubs))
+	return sub, nil
+}
+
+// Unsubscribe ends the subscription with the given ID, terminating it
+func (s *videoReplayVideo) Unsubscribe(
+	ctx context.Context,
+	id rtppassthrough.SubscriptionID,
+) error {
+	s.rtpMu.Lock()
+	subscriber, ok := s.rtpSubs[id]
+	if !ok {
+		s.rtpMu.Unlock()
+		return fmt.Errorf("unknown RTP subscription %s", id)
+	}
+	delete(s.rtpSubs, id)
+	if len(s.rtpSubs) == 0 && s.rtpCancel != nil {
+		s.rtpCancel()
+		s.rtpCancel = nil
+	}
+	s.rtpMu.Unlock()
+
+	subscriber.buf.Close()
+	s.logger.Infof("[Unsubscribe] Removed subscription %s for %q", id, s.name)
+	return nil
+}
+
+// closeRTP terminates every subscription and waits for the RTP loop to exit
+func (s *videoReplayVideo) closeRTP() {
+	s.rtpMu.Lock()
+	subs := s.rtpSubs
+	s.rtpSubs = nil
+	if s.rtpCancel != nil {
+		s.rtpCancel()
+		s.rtpCancel = nil
+	}
+	s.rtpMu.Unlock()
+
+	for _, subscriber := range subs {
+		subscriber.buf.Close()
+	}
+	s.rtpWG.Wait()
+}
+
+// rtpLoop sends each new frame to the subscribers until ctx is cancelled. It
+// queues a second of frames, since dropping one breaks H.264 passthrough
+// until the next keyframe; if frames are dropped anyway, every subscriber
+// waits for that keyframe rather than receiving a corrupt stream.
+func (s *videoReplayVideo) rtpLoop(ctx context.Context) {
+	enc := &h264Encoder{logger: s.logger}
+	defer enc.close()
+
+	sub := s.subscribeFrames(rtpFrameQueue, dropOldest)
+	defer s.frames.unsubscribe(sub)
+	passthrough := false
+	var dropped uint64
+	for {
+		var frame *replayFrame
+		repeat := false
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if ev.err != nil {
+				continue
+			}
+			frame, repeat = ev.frame, ev.repeat
+		}
+
+		var err error
+		au := frame.meta.h264
+		if passthrough != (au != nil) {
+			passthrough = au != nil
+			s.logger.Infof("[rtpLoop] H.264 passthrough for %q: %v", s.name, passthrough)
+		}
+		if n := sub.Dropped(); n != dropped {
+			if passthrough {
+				s.logger.Warnf("[rtpLoop] Fell %d frames behind for %q, resyncing at the next keyframe", n-dropped, s.name)
+				s.resyncRTP()
+			}
+			dropped = n
+		}
+		if passthrough && frame.meta.h264Gap {
+			s.logger.Debugf("[rtpLoop] Frames were skipped before this one for %q, resyncing at the next keyframe", s.name)
+			s.resyncRTP()
+		}
+		if passthrough && repeat {
+			// Sending an access unit twice would corrupt the decoder's reference frames
+			frame.release()
+			continue
+		}
+		if passthrough {
+			enc.close()
+		} else {
+			au, err = enc.encode(ctx, frame)
+		}
+		frame.release()
+		if err != nil {
+			s.logger.Warnf("[rtpLoop] Failed to encode frame for %q: %v", s.name, err)
+			continue
+		}
+		s.publishRTP(ctx, au)
+	}
+}
+
+// publishRTP packetizes one H.264 access unit for every subscriber. Subscribers
+// that haven't received a keyframe yet skip frames until the next one.
+func (s *videoReplayVideo) publishRTP(ctx context.Context, au []byte) {
+	keyframe := isH264Keyframe(au)
+	now := time.Now()
+
+	s.rtpMu.Lock()
+	defer s.rtpMu.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+	for id, subscriber := range s.rtpSubs {
+		if !subscriber.started && !keyframe {
+			continue
+		}
+		var samples uint32
+		if !subscriber.lastSent.IsZero() {
+			samples = uint32(now.Sub(subscriber.lastSent).Seconds() * rtpClockRate)
+		}
+		subscriber.started = true
+		subscriber.lastSent = now
+
+		pkts := subscriber.packetizer.Packetize(au, samples)
+		callback := subscriber.callback
+		if err := subscriber.buf.Publish(func() { callback(pkts) }); err != nil {
+			// The decoder can't follow past a lost frame, so start over at a keyframe
+			s.logger.Debugf("[publishRTP] Dropped frame for subscription %s, resyncing: %v", id, err)
+			subscriber.started = false
+		}
+	}
+}
+
+// resyncRTP makes every subscriber wait for the next keyframe, after frames
+// were lost before they could be sent
+func (s *videoReplayVideo) resyncRTP() {
+	s.rtpMu.Lock()
+	defer s.rtpMu.Unlock()
+	for _, subscriber := range s.rtpSubs {
+		subscriber.started = false
+	}
+}
+
+// h264Encoder encodes frames with x264, recreating the encoder when the frame
+// size changes
+type h264Encoder struct {
+	logger        logging.Logger
+	enc           codec.VideoEncoder
+	width, height int
+}
+
+// encode returns the frame as an Annex B H.264 access unit
+func (e *h264Encoder) encode(ctx context.Context, frame *replayFrame) ([]byte, error) {
+	if e.enc == nil || e.width != frame.width || e.height != frame.height {
+		e.close()
+		enc, err := x264.NewEncoder(frame.width, frame.height, codec.DefaultKeyFrameInterval, e.logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create H.264 encoder for %dx%d frames: %w", frame.width, frame.height, err)
+		}
+		e.enc, e.width, e.height = enc, frame.width, frame.height
+	}
+	img, err := frame.Image()
+	if err != nil {
+		return nil, err
+	}
+	return e.enc.Encode(ctx, img)
+}
+
+// close releases the encoder, if any
+func (e *h264Encoder) close() {
+	if e.enc != nil {
+		if err := e.enc.Close(); err != nil {
+			e.logger.Debugf("[h264Encoder] Failed to close encoder: %v", err)
+		}
+		e.enc = nil
+	}
+}
+
+// isH264Keyframe reports whether an Annex B access unit contains an IDR slice
+func isH264Keyframe(au []byte) bool {
+	zeros := 0
+	for i, b := range au {
+		switch {
+		case b == 0:
+			zeros++
+			continue
+		case b == 1 && zeros >= 2 && i+1 < len(au):
+			if au[i+1]&0x1F == 5 {
+				return true
+			}
+		}
+		zeros = 0
+	}
+	return false
+}
+
+// openH264Capture opens a second, raw mode capture of an H.264 file so its
+// access units can be passed through to RTP subscribers next to the decoded
+// frames. It returns nil when the file isn't H.264, frames are transformed,
+// or the backend can't return raw packets.
+func (s *videoReplayVideo) openH264Capture(path string) *gocv.VideoCapture {
+	cap, err := gocv.VideoCaptureFile(path)
+	if err != nil {
+		return nil
+	}
+	width := int(cap.Get(gocv.VideoCaptureFrameWidth))
+	height := int(cap.Get(gocv.VideoCaptureFrameHeight))
+	if !h264FourCCs[cap.CodecString()] || !s.canPassthrough(width, height) {
+		cap.Close()
+		return nil
+	}
+	cap.Set(gocv.VideoCaptureFormat, -1)
+	if cap.Get(gocv.VideoCaptureFormat) != -1 {
+		cap.Close()
+		return nil
+	}
+	s.logger.Infof("[openH264Capture] %q is H.264; RTP subscribers get the source stream", path)
+	return cap
+}
+
+// retrieveH264 returns the access unit grabbed with the frame being decoded.
+// The raw capture is dropped if it falls out of step with the decoded one.
+func (s *videoReplayVideo) retrieveH264() []byte {
+	if s.h264Capture == nil {
+		return nil
+	}
+	mat := gocv.NewMat()
+	defer mat.Close()
+	if ok := s.h264Capture.Retrieve(&mat); !ok || mat.Empty() || mat.Rows() != 1 {
+		s.logger.Warnf("[retrieveH264] Lost H.264 passthrough for %q; encoding instead", s.name)
+		s.h264Capture.Close()
+		s.h264Capture = nil
+		return nil
+	}
+	return mat.ToBytes()
+}
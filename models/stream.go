@@ -0,0 +1,76 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"sync"
+
+	"go.viam.com/rdk/gostream"
+)
+
+// errStreamClosed is returned by Next once the stream or camera is closed
+var errStreamClosed = errors.New("stream closed")
+
+// frameStream is a gostream.VideoStream over the camera's frame subscription.
+// Each Next returns the next new frame, so viewers get frames as they are
+// produced without polling. A slow viewer skips to the latest frame.
+type frameStream struct {
+	cam         *videoReplayVideo
+	sub         *frameSubscription
+	errHandlers []gostream.ErrorHandler
+	closeOnce   sync.Once
+}
+
+// Stream returns a stream of frames as the replay produces them
+func (s *videoReplayVideo) Stream(
+	ctx context.Context,
+	errHandlers ...gostream.ErrorHandler,
+) (gostream.VideoStream, error) {
+	if s.mainCtx.Err() != nil {
+		return nil, fmt.Errorf("camera %q is closed", s.name)
+	}
+	s.logger.Infof("[Stream] Opening stream for %q", s.name)
+	return &frameStream{
+		cam:         s,
+		sub:         s.subscribeFrames(1, dropOldest),
+		errHandlers: errHandlers,
+	}, nil
+}
+
+// Next returns the next frame, waiting for one if none is queued. The release
+// func must be called once the image is no longer used.
+func (fs *frameStream) Next(ctx context.Context) (image.Image, func(), error) {
+	var ev frameEvent
+	select {
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	case next, ok := <-fs.sub.C:
+		if !ok {
+			return nil, nil, errStreamClosed
+		}
+		ev = next
+	}
+
+	if ev.err != nil {
+		for _, handler := range fs.errHandlers {
+			handler(ctx, ev.err)
+		}
+		return nil, nil, ev.err
+	}
+
+	img, err := ev.frame.Image()
+	if err != nil {
+		ev.release()
+		return nil, nil, fmt.Errorf("frame to image fail: %w", err)
+	}
+	var releaseOnce sync.Once
+	return img, func() { releaseOnce.Do(ev.release) }, nil
+}
+
+// Close stops the stream; pending and later Next calls fail
+func (fs *frameStream) Close(ctx context.Context) error {
+	fs.closeOnce.Do(func() { fs.cam.frames.unsubscribe(fs.sub) })
+	return nil
+}
@@ -0,0 +1,237 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// maxGrabAheadMsec is how far ahead of the current frame a synced video reads
+// through frames rather than seeking
+const maxGrabAheadMsec = 2000
+
+// probeTimeline lays the video files out on one timeline. Files with known
+// capture times are placed by them; others follow the previous file.
+func (s *videoReplayVideo) probeTimeline(files []videoFile) {
+	var end time.Duration
+	for i := range files {
+		f := &files[i]
+		f.fps, f.duration = 30, 0
+		if cap, err := gocv.VideoCaptureFile(f.path); err == nil {
+			if fps := cap.Get(gocv.VideoCaptureFPS); fps > 0 {
+				f.fps = fps
+			}
+			frames := cap.Get(gocv.VideoCaptureFrameCount)
+			f.duration = time.Duration(frames / f.fps * float64(time.Second))
+			cap.Close()
+		} else {
+			s.logger.Warnf("[probeTimeline] Failed to open %q: %v", f.path, err)
+		}
+
+		f.offset = end
+		if i > 0 && !f.startTime.IsZero() && !files[0].startTime.IsZero() {
+			f.offset = f.startTime.Sub(files[0].startTime)
+		}
+		end = f.offset + f.duration
+	}
+}
+
+// syncedVideoLoop shows the video frame at the sync group clock's position
+func (s *videoReplayVideo) syncedVideoLoop(ctx context.Context, fps float64) {
+	s.logger.Infof("[syncedVideoLoop] Starting for camera %q in sync group %q, checking at FPS=%.3f",
+		s.name, s.clock.name, fps)
+	faults := s.startFaults()
+	ticker := time.NewTicker(frameInterval(fps))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Infof("[syncedVideoLoop] canceled for %q", s.name)
+			return
+		case <-ticker.C:
+			s.syncVideoTo(ctx, s.clock.sourcePosition(s), faults)
+		}
+	}
+}
+
+// syncVideoTo makes the frame at position t of the video timeline current,
+// opening the file that contains it and seeking or skipping frames as needed.
+// Injected faults apply to the frame it lands on.
+func (s *videoReplayVideo) syncVideoTo(ctx context.Context, t time.Duration, faults *faultInjector) {
+	files := s.videoFiles
+	last := files[len(files)-1]
+	total := last.offset + last.duration
+	loop := true
+	if s.cfg.LoopVideo != nil {
+		loop = *s.cfg.LoopVideo
+	}
+	switch {
+	case t < 0:
+		t = 0
+	case total > 0 && t >= total && loop:
+		t %= total
+	case total > 0 && t >= total:
+		t = total - time.Millisecond
+	}
+
+	index := sort.Search(len(files), func(i int) bool { return files[i].offset > t }) - 1
+	index = max(0, index)
+	if index != s.videoIndex {
+		cap, raw, err := s.openCapture(files[index].path)
+		if err != nil {
+			s.logger.Errorf("[syncVideoTo] Failed to open %q: %v", files[index].path, err)
+			return
+		}
+		s.setCapture(cap, raw, files[index].path)
+		s.videoIndex = index
+		s.startFile()
+	}
+
+	target := float64(t-files[index].offset) / float64(time.Millisecond)
+	interval := 1000 / files[index].fps
+	switch {
+	case s.filePts >= 0 && target >= s.filePts && target < s.filePts+interval:
+		// Already showing the frame for target
+		return
+	case s.filePts < 0 && target < interval:
+		// Freshly opened at the start; the next frame is the one
+	case s.filePts < 0 || target < s.filePts || target > s.filePts+maxGrabAheadMsec:
+		s.seekCapture(target)
+	default:
+		for skip := int((target-s.filePts)/interval) - 1; skip > 0; skip-- {
+			if !s.grabFrame() {
+				return
+			}
+			s.skipFrame()
+			s.droppedFrames.Add(1)
+		}
+	}
+
+	// A dropped or duplicated frame is still read past, so it isn't shown later
+	switch faults.next() {
+	case faultDrop:
+		if s.grabFrame() {
+			s.skipFrame()
+		}
+	case faultDuplicate:
+		if s.grabFrame() {
+			s.skipFrame()
+		}
+		s.duplicateFrame()
+	default:
+		if sleepCtx(ctx, faults.jitter()) {
+			s.readFrame()
+		}
+	}
+}
+
+// seekCapture moves the capture to the frame at target milliseconds into the
+// file. The raw H.264 capture can't seek in step, so passthrough stops until
+// the next file is opened.
+func (s *videoReplayVideo) seekCapture(target float64) {
+	s.videoCapture.Set(gocv.VideoCapturePosMsec, target)
+	s.filePts = -1
+	if s.h264Capture != nil {
+		s.h264Capture.Close()
+		s.h264Capture = nil
+		s.logger.Infof("[seekCapture] Seeked %q; encoding H.264 until the next file", s.name)
+	}
+}
+
+// syncedDatasetLoop shows the dataset image at the sync group clock's position
+func (s *videoReplayVideo) syncedDatasetLoop(ctx context.Context, fps float64) {
+	s.logger.Infof("[syncedDatasetLoop] Starting for camera %q in sync group %q, checking at FPS=%.3f",
+		s.name, s.clock.name, fps)
+	faults := s.startFaults()
+	ticker := time.NewTicker(frameInterval(fps))
+	defer ticker.Stop()
+
+	shown := -1
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Infof("[syncedDatasetLoop] canceled for %q", s.name)
+			return
+		case <-ticker.C:
+			index := s.datasetReplay.indexAt(s.clock.sourcePosition(s), frameInterval(fps))
+			if index == shown {
+				continue
+			}
+			shown = index
+			switch faults.next() {
+			case faultDrop:
+				continue
+			case faultDuplicate:
+				s.duplicateFrame()
+				continue
+			}
+			if !sleepCtx(ctx, faults.jitter()) {
+				s.logger.Infof("[syncedDatasetLoop] canceled for %q", s.name)
+				return
+			}
+			s.datasetReplay.seekIndex(index)
+			if err := s.datasetReplay.loadNextFrame(s); err != nil {
+				s.logger.Errorf("[syncedDatasetLoop] Failed to load frame %d: %v", index, err)
+			}
+		}
+	}
+}
+
+// indexAt returns the image captured at position t since the first image,
+// wrapping around at the end. The last image is shown for one frame interval.
+func (dr *DatasetReplay) indexAt(t, interval time.Duration) int {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+	if len(dr.images) == 0 {
+		return 0
+	}
+	origin := dr.images[0].Timestamp
+	span := dr.images[len(dr.images)-1].Timestamp.Sub(origin) + interval
+	t = max(0, t)
+	if span > 0 {
+		t %= span
+	}
+	index := sort.Search(len(dr.images), func(i int) bool { return dr.images[i].Timestamp.Sub(origin) > t }) - 1
+	return max(0, index)
+}
+
+// seekIndex makes index the next image loadNextFrame loads
+func (dr *DatasetReplay) seekIndex(index int) {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+	if index >= 0 && index < len(dr.images) {
+		dr.currentIndex = index
+	}
+}
+
+// syncCommand handles the sync group DoCommands
+func (s *videoReplayVideo) syncCommand(name string, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if s.clock == nil {
+		return nil, fmt.Errorf("camera %q is not in a sync_group", s.name)
+	}
+	return s.clock.command(name, cmd)
+}
+
+// updateSyncGroup joins the configured sync group, leaving the previous one if
+// it changed
+func (s *videoReplayVideo) updateSyncGroup() {
+	name := ""
+	if s.cfg.SyncGroup != nil {
+		name = *s.cfg.SyncGroup
+	}
+	if s.clock != nil && s.clock.name == name {
+		return
+	}
+	if s.clock != nil {
+		s.clock.leave(s)
+		s.clock = nil
+	}
+	if name != "" {
+		s.clock = joinSyncGroup(name, s)
+		s.logger.Infof("[updateSyncGroup] Camera %q joined sync group %q", s.name, name)
+	}
+}
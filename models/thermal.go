@@ -0,0 +1,437 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"gocv.io/x/gocv"
+)
+
+// Thermal is the camera model replaying recorded thermal arrays
+var Thermal = resource.NewModel("bill", "camera", "thermal-replay")
+
+func init() {
+	resource.RegisterComponent(
+		camera.API,
+		Thermal,
+		resource.Registration[camera.Camera, *ThermalConfig]{
+			Constructor: newThermalReplay,
+		},
+	)
+}
+
+// defaultThermalFPS is the playback rate when fps isn't configured, a common
+// rate for low resolution thermal arrays
+const defaultThermalFPS = 4
+
+// ThermalConfig holds the JSON attributes of a thermal replay. The playback and
+// output fields (fps, loop_video, width, height, fit, encoding, timestamp_mode,
+// sync_group, debug_http_port, calibration_path) are shared with the video
+// replay.
+type ThermalConfig struct {
+	Config `json:",squash"`
+
+	ThermalPath    *string  `json:"thermal_path,omitempty"`    // frame file or directory of frames: 16-bit PNG, .npy or .csv
+	RecordingStart *string  `json:"recording_start,omitempty"` // RFC3339 capture time of the first frame, to align with a sync_group
+	RawScale       *float64 `json:"raw_scale,omitempty"`       // temperature = raw * raw_scale + raw_offset (default 1)
+	RawOffset      *float64 `json:"raw_offset,omitempty"`      // e.g. 0.01 and -273.15 for PNGs in centikelvin (default 0)
+	Colormap       *string  `json:"colormap,omitempty"`        // "inferno" (default), "jet", "hot", "gray", ...; see thermalColormaps
+	MinTemp        *float64 `json:"min_temp,omitempty"`        // temperature at the bottom of the colormap; each frame's minimum if unset
+	MaxTemp        *float64 `json:"max_temp,omitempty"`        // temperature at the top of the colormap; each frame's maximum if unset
+}
+
+// grayColormap renders temperatures as grayscale rather than through an OpenCV colormap
+const grayColormap = "gray"
+
+// thermalColormaps are the colormap names thermal frames can be rendered with.
+// gocv only names OpenCV's original colormaps; the perceptual ones are
+// referenced by their OpenCV values.
+var thermalColormaps = map[string]gocv.ColormapTypes{
+	"autumn":  gocv.ColormapAutumn,
+	"bone":    gocv.ColormapBone,
+	"jet":     gocv.ColormapJet,
+	"rainbow": gocv.ColormapRainbow,
+	"ocean":   gocv.ColormapOcean,
+	"cool":    gocv.ColormapCool,
+	"hot":     gocv.ColormapHot,
+	"parula":  gocv.ColormapParula,
+	"magma":   gocv.ColormapTypes(13),
+	"inferno": gocv.ColormapTypes(14),
+	"plasma":  gocv.ColormapTypes(15),
+	"viridis": gocv.ColormapTypes(16),
+	"turbo":   gocv.ColormapTypes(20),
+}
+
+// Validate ensures the thermal source is set and the shared fields are valid
+func (c *ThermalConfig) Validate(path string) ([]string, []string, error) {
+	if c.ThermalPath == nil || *c.ThermalPath == "" {
+		return nil, nil, fmt.Errorf("thermal_path is required for the thermal replay camera")
+	}
+	if c.Mode != nil || c.VideoPath != nil {
+		return nil, nil, fmt.Errorf("mode and video_path are not used by the thermal replay camera")
+	}
+	if _, err := c.recordingStart(); err != nil {
+		return nil, nil, err
+	}
+	if c.RawScale != nil && *c.RawScale == 0 {
+		return nil, nil, fmt.Errorf("raw_scale must not be zero")
+	}
+	if c.Colormap != nil && *c.Colormap != grayColormap {
+		if _, ok := thermalColormaps[*c.Colormap]; !ok {
+			return nil, nil, fmt.Errorf("invalid colormap '%s'", *c.Colormap)
+		}
+	}
+	if c.MinTemp != nil && c.MaxTemp != nil && *c.MinTemp >= *c.MaxTemp {
+		return nil, nil, fmt.Errorf("min_temp %v must be below max_temp %v", *c.MinTemp, *c.MaxTemp)
+	}
+
+	if err := c.validatePlayback(); err != nil {
+		return nil, nil, err
+	}
+	return c.dependencies(), nil, nil
+}
+
+// recordingStart parses recording_start; zero when it isn't set
+func (c *ThermalConfig) recordingStart() (time.Time, error) {
+	if c.RecordingStart == nil || *c.RecordingStart == "" {
+		return time.Time{}, nil
+	}
+	start, err := time.Parse(time.RFC3339Nano, *c.RecordingStart)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid recording_start %q: must be RFC3339: %w", *c.RecordingStart, err)
+	}
+	return start, nil
+}
+
+// thermalFrame is one recorded array of temperatures, row by row
+type thermalFrame struct {
+	width, height int
+	values        []float64
+	min, max      float64 // ignoring NaNs
+	file          string
+}
+
+// thermalReplay holds the recorded frames and how to render them
+type thermalReplay struct {
+	frames   []*thermalFrame
+	start    time.Time // capture time of the first frame; zero if unknown
+	colormap string
+	minTemp  *float64
+	maxTemp  *float64
+}
+
+// newThermalReplay builds a replay camera showing thermal frames as colormapped images
+func newThermalReplay(
+	ctx context.Context,
+	deps resource.Dependencies,
+	rawConf resource.Config,
+	logger logging.Logger,
+) (camera.Camera, error) {
+	logger.Infof("[newThermalReplay] Called")
+
+	conf, err := resource.NativeConfig[*ThermalConfig](rawConf)
+	if err != nil {
+		return nil, err
+	}
+	thermal, err := loadThermalReplay(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	cam, err := newReplayCamera(rawConf.ResourceName(), deps, &conf.Config, "thermal", logger)
+	if err != nil {
+		return nil, err
+	}
+	cam.thermal = thermal
+
+	if err := cam.initThermalReplay(); err != nil {
+		cam.Close(context.Background())
+		return nil, fmt.Errorf("failed to initialize thermal replay: %w", err)
+	}
+	if err := cam.startDebugServer(); err != nil {
+		cam.Close(context.Background())
+		return nil, err
+	}
+
+	logger.Infof("[newThermalReplay] Camera constructed successfully: %q (%d frames)", cam.name, len(thermal.frames))
+	return cam, nil
+}
+
+// loadThermalReplay reads every frame under thermal_path
+func loadThermalReplay(conf *ThermalConfig) (*thermalReplay, error) {
+	start, err := conf.recordingStart()
+	if err != nil {
+		return nil, err
+	}
+	scale, offset := 1.0, 0.0
+	if conf.RawScale != nil {
+		scale = *conf.RawScale
+	}
+	if conf.RawOffset != nil {
+		offset = *conf.RawOffset
+	}
+	frames, err := readThermalFrames(*conf.ThermalPath, scale, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	colormap := "inferno"
+	if conf.Colormap != nil {
+		colormap = *conf.Colormap
+	}
+	return &thermalReplay{
+		frames:   frames,
+		start:    start,
+		colormap: colormap,
+		minTemp:  conf.MinTemp,
+		maxTemp:  conf.MaxTemp,
+	}, nil
+}
+
+// initThermalReplay shows the first frame and starts playback, following the
+// sync group clock when there is one
+func (s *videoReplayVideo) initThermalReplay() error {
+	fps := float64(defaultThermalFPS)
+	if s.cfg.FPS != nil {
+		fps = *s.cfg.FPS
+	}
+	s.fps = fps
+
+	if s.clock != nil {
+		s.clock.setSourceStart(s, s.thermal.start)
+	}
+	s.startThermal()
+	if err := s.showThermalFrame(0); err != nil {
+		return err
+	}
+
+	loopCtx, loopCancel := context.WithCancel(s.mainCtx)
+	s.loopCtx = loopCtx
+	s.loopCancel = loopCancel
+
+	s.loopWG.Add(1)
+	go func() {
+		defer s.loopWG.Done()
+		if s.clock != nil {
+			s.syncedThermalLoop(loopCtx, fps)
+			return
+		}
+		if s.awaitTrigger(loopCtx) {
+			s.thermalReplayLoop(loopCtx, fps)
+		}
+	}()
+	return nil
+}
+
+// startThermal sets the capture time frame 0 is stamped with: the recorded
+// start when known, otherwise now. Each pass starts the recording over.
+func (s *videoReplayVideo) startThermal() {
+	s.fileStart = s.thermal.start
+	if s.fileStart.IsZero() {
+		s.fileStart = time.Now()
+	}
+	s.recordingStart = s.fileStart
+}
+
+// thermalReplayLoop shows the frames one after another at fps
+func (s *videoReplayVideo) thermalReplayLoop(ctx context.Context, fps float64) {
+	s.logger.Infof("[thermalReplayLoop] Starting for camera %q at FPS=%.3f", s.name, fps)
+	faults := s.startFaults()
+	ticker := time.NewTicker(frameInterval(fps))
+	defer ticker.Stop()
+
+	index := 0
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Infof("[thermalReplayLoop] canceled for %q", s.name)
+			return
+		case <-ticker.C:
+			index++
+			if index >= len(s.thermal.frames) {
+				shouldLoop := true
+				if s.cfg.LoopVideo != nil {
+					shouldLoop = *s.cfg.LoopVideo
+				}
+				if !shouldLoop {
+					s.logger.Infof("[thermalReplayLoop] End of frames => stopping playback for %q (loop disabled)", s.name)
+					return
+				}
+				index = 0
+				s.startThermal()
+			}
+			if !s.showThermalFaulted(ctx, index, faults) {
+				s.logger.Infof("[thermalReplayLoop] canceled for %q", s.name)
+				return
+			}
+		}
+	}
+}
+
+// showThermalFaulted shows frame index unless an injected fault drops or
+// repeats it instead. It returns false if ctx ends during jitter.
+func (s *videoReplayVideo) showThermalFaulted(ctx context.Context, index int, faults *faultInjector) bool {
+	switch faults.next() {
+	case faultDrop:
+		return true
+	case faultDuplicate:
+		s.duplicateFrame()
+		return true
+	}
+	if !sleepCtx(ctx, faults.jitter()) {
+		return false
+	}
+	if err := s.showThermalFrame(index); err != nil {
+		s.logger.Errorf("[showThermalFaulted] Failed to show frame %d: %v", index, err)
+	}
+	return true
+}
+
+// syncedThermalLoop shows the thermal frame at the sync group clock's position
+func (s *videoReplayVideo) syncedThermalLoop(ctx context.Context, fps float64) {
+	s.logger.Infof("[syncedThermalLoop] Starting for camera %q in sync group %q, checking at FPS=%.3f",
+		s.name, s.clock.name, fps)
+	faults := s.startFaults()
+	interval := frameInterval(fps)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	shouldLoop := true
+	if s.cfg.LoopVideo != nil {
+		shouldLoop = *s.cfg.LoopVideo
+	}
+	shown := 0
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Infof("[syncedThermalLoop] canceled for %q", s.name)
+			return
+		case <-ticker.C:
+			index := s.thermal.indexAt(s.clock.sourcePosition(s), interval, shouldLoop)
+			if index == shown {
+				continue
+			}
+			shown = index
+			if !s.showThermalFaulted(ctx, index, faults) {
+				s.logger.Infof("[syncedThermalLoop] canceled for %q", s.name)
+				return
+			}
+		}
+	}
+}
+
+// indexAt returns the frame shown at position t since the first frame, wrapping
+// around at the end when looping and holding the last frame otherwise
+func (t *thermalReplay) indexAt(pos, interval time.Duration, loop bool) int {
+	index := int(max(0, pos) / interval)
+	if index >= len(t.frames) {
+		if loop {
+			return index % len(t.frames)
+		}
+		return len(t.frames) - 1
+	}
+	return index
+}
+
+// showThermalFrame renders frame index and makes it the current frame
+func (s *videoReplayVideo) showThermalFrame(index int) error {
+	tf := s.thermal.frames[index]
+	mat, err := s.thermal.render(tf)
+	if err != nil {
+		return fmt.Errorf("failed to render %q: %w", tf.file, err)
+	}
+	position := time.Duration(index) * frameInterval(s.fps)
+	s.setFrame(mat, frameMeta{
+		capturedAt: s.fileStart.Add(position),
+		file:       tf.file,
+		frameIndex: index,
+		position:   position,
+		thermal:    tf,
+	})
+	return nil
+}
+
+// render maps the frame's temperatures onto the colormap, over min_temp to
+// max_temp or the frame's own range
+func (t *thermalReplay) render(tf *thermalFrame) (gocv.Mat, error) {
+	lo, hi := tf.min, tf.max
+	if t.minTemp != nil {
+		lo = *t.minTemp
+	}
+	if t.maxTemp != nil {
+		hi = *t.maxTemp
+	}
+
+	pixels := make([]byte, len(tf.values))
+	if span := hi - lo; span > 0 {
+		for i, v := range tf.values {
+			if math.IsNaN(v) {
+				continue
+			}
+			pixels[i] = byte(math.Round(255 * min(1, max(0, (v-lo)/span))))
+		}
+	}
+	gray, err := gocv.NewMatFromBytes(tf.height, tf.width, gocv.MatTypeCV8UC1, pixels)
+	if err != nil {
+		return gocv.Mat{}, err
+	}
+	defer gray.Close()
+
+	out := gocv.NewMat()
+	if t.colormap == grayColormap {
+		gocv.CvtColor(gray, &out, gocv.ColorGrayToBGR)
+	} else {
+		gocv.ApplyColorMap(gray, &out, thermalColormaps[t.colormap])
+	}
+	return out, nil
+}
+
+// temperaturesCommand reports the temperatures behind the current frame, row
+// by row, with their range and the frame's provenance
+func (s *videoReplayVideo) temperaturesCommand() (map[string]interface{}, error) {
+	if s.thermal == nil {
+		return nil, fmt.Errorf("camera %q does not replay thermal frames", s.name)
+	}
+	frame, err := s.acquireFrame()
+	if err != nil {
+		return nil, err
+	}
+	defer frame.release()
+	tf := frame.meta.thermal
+	if tf == nil {
+		return nil, fmt.Errorf("no thermal frame available")
+	}
+
+	rows := make([]interface{}, tf.height)
+	sum, count := 0.0, 0
+	for y := range rows {
+		row := make([]interface{}, tf.width)
+		for x := range row {
+			v := tf.values[y*tf.width+x]
+			if math.IsNaN(v) {
+				row[x] = nil
+				continue
+			}
+			row[x] = v
+			sum += v
+			count++
+		}
+		rows[y] = row
+	}
+
+	info := frame.meta.provenance()
+	info["width"] = tf.width
+	info["height"] = tf.height
+	info["temperatures"] = rows
+	if count > 0 {
+		info["min"] = tf.min
+		info["max"] = tf.max
+		info["mean"] = sum / float64(count)
+	}
+	return info, nil
+}
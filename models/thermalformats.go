@@ -0,0 +1,295 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// thermalExtensions are the file types thermal frames are read from
+var thermalExtensions = map[string]bool{
+	".png": true,
+	".npy": true,
+	".csv": true,
+}
+
+// readThermalFrames reads the frames in path, a frame file or a directory of
+// them played in name order, converting raw values to temperatures
+func readThermalFrames(path string, scale, offset float64) ([]*thermalFrame, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open thermal_path: %w", err)
+	}
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list thermal_path: %w", err)
+		}
+		files = files[:0]
+		for _, entry := range entries {
+			if !entry.IsDir() && thermalExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+		sort.Strings(files)
+	}
+
+	var frames []*thermalFrame
+	for _, file := range files {
+		read, err := readThermalFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read thermal frames from %q: %w", file, err)
+		}
+		for _, tf := range read {
+			tf.file = filepath.Base(file)
+			tf.scale(scale, offset)
+			frames = append(frames, tf)
+		}
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("no thermal frames found in %q", path)
+	}
+	return frames, nil
+}
+
+// readThermalFile reads the raw frames in one file by its extension
+func readThermalFile(path string) ([]*thermalFrame, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		return readThermalPNG(path)
+	case ".npy":
+		return readThermalNPY(path)
+	case ".csv":
+		return readThermalCSV(path)
+	default:
+		return nil, fmt.Errorf("unsupported thermal frame type (want .png, .npy or .csv)")
+	}
+}
+
+// scale converts raw values to temperatures and records their range
+func (tf *thermalFrame) scale(scale, offset float64) {
+	tf.min, tf.max = math.NaN(), math.NaN()
+	for i, v := range tf.values {
+		v = v*scale + offset
+		tf.values[i] = v
+		if math.IsNaN(v) {
+			continue
+		}
+		if math.IsNaN(tf.min) || v < tf.min {
+			tf.min = v
+		}
+		if math.IsNaN(tf.max) || v > tf.max {
+			tf.max = v
+		}
+	}
+}
+
+// readThermalPNG reads a single-channel 8 or 16-bit PNG as one frame
+func readThermalPNG(path string) ([]*thermalFrame, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	tf := &thermalFrame{width: bounds.Dx(), height: bounds.Dy()}
+	tf.values = make([]float64, 0, tf.width*tf.height)
+	switch img := img.(type) {
+	case *image.Gray16:
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				tf.values = append(tf.values, float64(img.Gray16At(x, y).Y))
+			}
+		}
+	case *image.Gray:
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				tf.values = append(tf.values, float64(img.GrayAt(x, y).Y))
+			}
+		}
+	default:
+		return nil, fmt.Errorf("PNG must be single-channel grayscale, got %T", img)
+	}
+	return []*thermalFrame{tf}, nil
+}
+
+// NPY header fields, as written by numpy.save
+var (
+	npyDescr   = regexp.MustCompile(`'descr':\s*'([^']*)'`)
+	npyFortran = regexp.MustCompile(`'fortran_order':\s*(True|False)`)
+	npyShape   = regexp.MustCompile(`'shape':\s*\(([^)]*)\)`)
+)
+
+// readThermalNPY reads a NumPy array: one frame of (height, width) or a stack
+// of (frames, height, width)
+func readThermalNPY(path string) ([]*thermalFrame, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 10 || string(data[:6]) != "\x93NUMPY" {
+		return nil, fmt.Errorf("not a NumPy .npy file")
+	}
+	var headerLen, pos int
+	switch data[6] {
+	case 1:
+		headerLen, pos = int(binary.LittleEndian.Uint16(data[8:10])), 10
+	case 2, 3:
+		if len(data) < 12 {
+			return nil, fmt.Errorf("truncated .npy header")
+		}
+		headerLen, pos = int(binary.LittleEndian.Uint32(data[8:12])), 12
+	default:
+		return nil, fmt.Errorf("unsupported .npy version %d", data[6])
+	}
+	if pos+headerLen > len(data) {
+		return nil, fmt.Errorf("truncated .npy header")
+	}
+	header, body := string(data[pos:pos+headerLen]), data[pos+headerLen:]
+
+	descr := npyDescr.FindStringSubmatch(header)
+	fortran := npyFortran.FindStringSubmatch(header)
+	shapeMatch := npyShape.FindStringSubmatch(header)
+	if descr == nil || fortran == nil || shapeMatch == nil {
+		return nil, fmt.Errorf("malformed .npy header %q", header)
+	}
+	if fortran[1] == "True" {
+		return nil, fmt.Errorf("fortran-ordered arrays are not supported")
+	}
+	var shape []int
+	for _, dim := range strings.Split(shapeMatch[1], ",") {
+		if dim = strings.TrimSpace(dim); dim == "" {
+			continue
+		}
+		n, err := strconv.Atoi(dim)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("malformed .npy shape %q", shapeMatch[1])
+		}
+		shape = append(shape, n)
+	}
+	count, height, width := 1, 0, 0
+	switch len(shape) {
+	case 2:
+		height, width = shape[0], shape[1]
+	case 3:
+		count, height, width = shape[0], shape[1], shape[2]
+	default:
+		return nil, fmt.Errorf("array must be 2D (height, width) or 3D (frames, height, width), got shape %v", shape)
+	}
+
+	value, size, err := npyElement(descr[1])
+	if err != nil {
+		return nil, err
+	}
+	// Multiply up the data size only while it fits in the body, so huge shapes can't overflow
+	want := size
+	for _, dim := range []int{count, height, width} {
+		if dim > len(body)/want {
+			return nil, fmt.Errorf("truncated .npy data: shape %v needs more than the %d bytes present", shape, len(body))
+		}
+		want *= dim
+	}
+
+	frames := make([]*thermalFrame, count)
+	for i := range frames {
+		tf := &thermalFrame{width: width, height: height, values: make([]float64, width*height)}
+		for j := range tf.values {
+			at := ((i * width * height) + j) * size
+			tf.values[j] = value(body[at : at+size])
+		}
+		frames[i] = tf
+	}
+	return frames, nil
+}
+
+// npyElement returns a decoder for one array element of the dtype descr and
+// the element's size in bytes
+func npyElement(descr string) (func([]byte) float64, int, error) {
+	if len(descr) < 3 {
+		return nil, 0, fmt.Errorf("unsupported .npy dtype %q", descr)
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if descr[0] == '>' {
+		order = binary.BigEndian
+	}
+	switch descr[1:] {
+	case "f4":
+		return func(b []byte) float64 { return float64(math.Float32frombits(order.Uint32(b))) }, 4, nil
+	case "f8":
+		return func(b []byte) float64 { return math.Float64frombits(order.Uint64(b)) }, 8, nil
+	case "u1":
+		return func(b []byte) float64 { return float64(b[0]) }, 1, nil
+	case "i1":
+		return func(b []byte) float64 { return float64(int8(b[0])) }, 1, nil
+	case "u2":
+		return func(b []byte) float64 { return float64(order.Uint16(b)) }, 2, nil
+	case "i2":
+		return func(b []byte) float64 { return float64(int16(order.Uint16(b))) }, 2, nil
+	case "u4":
+		return func(b []byte) float64 { return float64(order.Uint32(b)) }, 4, nil
+	case "i4":
+		return func(b []byte) float64 { return float64(int32(order.Uint32(b))) }, 4, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported .npy dtype %q", descr)
+	}
+}
+
+// readThermalCSV reads rows of comma-separated values. A blank line ends a
+// frame, so one file can hold several; lines starting with # are skipped.
+func readThermalCSV(path string) ([]*thermalFrame, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var frames []*thermalFrame
+	var current *thermalFrame
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(text, "#") {
+			continue
+		}
+		if text == "" {
+			current = nil
+			continue
+		}
+
+		fields := strings.Split(text, ",")
+		if current == nil {
+			current = &thermalFrame{width: len(fields)}
+			frames = append(frames, current)
+		}
+		if len(fields) != current.width {
+			return nil, fmt.Errorf("line %d has %d values, want %d", line, len(fields), current.width)
+		}
+		for _, field := range fields {
+			v, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			current.values = append(current.values, v)
+		}
+		current.height++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return frames, nil
+}
@@ -0,0 +1,252 @@
+package models
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// npyFile builds a version 1 .npy file with the given header dict and body
+func npyFile(header string, body []byte) []byte {
+	header += "\n"
+	var b bytes.Buffer
+	b.WriteString("\x93NUMPY\x01\x00")
+	binary.Write(&b, binary.LittleEndian, uint16(len(header)))
+	b.WriteString(header)
+	b.Write(body)
+	return b.Bytes()
+}
+
+// writeTemp writes data to name in a fresh temporary directory
+func writeTemp(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadThermalNPY(t *testing.T) {
+	f4 := make([]byte, 0, 16)
+	for _, v := range []float32{1.5, -2, 3.25, 40} {
+		f4 = binary.LittleEndian.AppendUint32(f4, math.Float32bits(v))
+	}
+	u2be := []byte{0x01, 0x00, 0x00, 0x02, 0xff, 0xff}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		frames  [][]float64
+		width   int
+		height  int
+		wantErr string
+	}{
+		{
+			name:   "2D float32",
+			data:   npyFile("{'descr': '<f4', 'fortran_order': False, 'shape': (2, 2), }", f4),
+			frames: [][]float64{{1.5, -2, 3.25, 40}},
+			width:  2, height: 2,
+		},
+		{
+			name:   "3D stack",
+			data:   npyFile("{'descr': '<f4', 'fortran_order': False, 'shape': (2, 1, 2), }", f4),
+			frames: [][]float64{{1.5, -2}, {3.25, 40}},
+			width:  2, height: 1,
+		},
+		{
+			name:   "big-endian uint16",
+			data:   npyFile("{'descr': '>u2', 'fortran_order': False, 'shape': (1, 3), }", u2be),
+			frames: [][]float64{{256, 2, 65535}},
+			width:  3, height: 1,
+		},
+		{
+			name:   "signed int8",
+			data:   npyFile("{'descr': '|i1', 'fortran_order': False, 'shape': (1, 2), }", []byte{0x80, 0x7f}),
+			frames: [][]float64{{-128, 127}},
+			width:  2, height: 1,
+		},
+		{
+			name:    "not npy",
+			data:    []byte("PK\x03\x04 not numpy at all"),
+			wantErr: "not a NumPy",
+		},
+		{
+			name:    "unsupported version",
+			data:    append([]byte("\x93NUMPY\x04\x00"), 0, 0, 0, 0),
+			wantErr: "unsupported .npy version",
+		},
+		{
+			name:    "truncated header",
+			data:    []byte("\x93NUMPY\x01\x00\xff\x00{'descr'"),
+			wantErr: "truncated .npy header",
+		},
+		{
+			name:    "missing shape",
+			data:    npyFile("{'descr': '<f4', 'fortran_order': False, }", f4),
+			wantErr: "malformed .npy header",
+		},
+		{
+			name:    "fortran order",
+			data:    npyFile("{'descr': '<f4', 'fortran_order': True, 'shape': (2, 2), }", f4),
+			wantErr: "fortran-ordered",
+		},
+		{
+			name:    "negative dimension",
+			data:    npyFile("{'descr': '<f4', 'fortran_order': False, 'shape': (-1, 4), }", f4),
+			wantErr: "malformed .npy shape",
+		},
+		{
+			name:    "zero dimension",
+			data:    npyFile("{'descr': '<f4', 'fortran_order': False, 'shape': (0, 4), }", f4),
+			wantErr: "malformed .npy shape",
+		},
+		{
+			name:    "1D array",
+			data:    npyFile("{'descr': '<f4', 'fortran_order': False, 'shape': (4,), }", f4),
+			wantErr: "must be 2D",
+		},
+		{
+			name:    "unsupported dtype",
+			data:    npyFile("{'descr': '<c8', 'fortran_order': False, 'shape': (1, 2), }", f4),
+			wantErr: "unsupported .npy dtype",
+		},
+		{
+			name:    "short body",
+			data:    npyFile("{'descr': '<f4', 'fortran_order': False, 'shape': (3, 2), }", f4),
+			wantErr: "truncated .npy data",
+		},
+		{
+			name:    "oversized shape",
+			data:    npyFile("{'descr': '<f8', 'fortran_order': False, 'shape': (4611686018427387904, 4611686018427387904, 4), }", f4),
+			wantErr: "truncated .npy data",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames, err := readThermalNPY(writeTemp(t, "frame.npy", tt.data))
+			checkThermalFrames(t, frames, err, tt.frames, tt.width, tt.height, tt.wantErr)
+		})
+	}
+}
+
+func TestReadThermalCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		frames  [][]float64
+		width   int
+		height  int
+		wantErr string
+	}{
+		{
+			name:   "single frame",
+			data:   "1,2,3\n4, 5 ,6\n",
+			frames: [][]float64{{1, 2, 3, 4, 5, 6}},
+			width:  3, height: 2,
+		},
+		{
+			name:   "frames split by blank lines with comments",
+			data:   "# exported frames\n1,2\n3,4\n\n\n# second\n5,6\n7,8\n",
+			frames: [][]float64{{1, 2, 3, 4}, {5, 6, 7, 8}},
+			width:  2, height: 2,
+		},
+		{
+			name:    "ragged row",
+			data:    "1,2,3\n4,5\n",
+			wantErr: "line 2 has 2 values, want 3",
+		},
+		{
+			name:    "not a number",
+			data:    "1,2\n3,hot\n",
+			wantErr: "line 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames, err := readThermalCSV(writeTemp(t, "frame.csv", []byte(tt.data)))
+			checkThermalFrames(t, frames, err, tt.frames, tt.width, tt.height, tt.wantErr)
+		})
+	}
+}
+
+func TestReadThermalPNG(t *testing.T) {
+	gray16 := image.NewGray16(image.Rect(0, 0, 2, 1))
+	gray16.SetGray16(0, 0, color.Gray16{Y: 30000})
+	gray16.SetGray16(1, 0, color.Gray16{Y: 65535})
+	gray := image.NewGray(image.Rect(0, 0, 1, 2))
+	gray.SetGray(0, 1, color.Gray{Y: 200})
+	rgba := image.NewRGBA(image.Rect(0, 0, 1, 1))
+
+	tests := []struct {
+		name    string
+		img     image.Image
+		frames  [][]float64
+		width   int
+		height  int
+		wantErr string
+	}{
+		{name: "16-bit", img: gray16, frames: [][]float64{{30000, 65535}}, width: 2, height: 1},
+		{name: "8-bit", img: gray, frames: [][]float64{{0, 200}}, width: 1, height: 2},
+		{name: "color", img: rgba, wantErr: "single-channel"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := png.Encode(&b, tt.img); err != nil {
+				t.Fatal(err)
+			}
+			frames, err := readThermalPNG(writeTemp(t, "frame.png", b.Bytes()))
+			checkThermalFrames(t, frames, err, tt.frames, tt.width, tt.height, tt.wantErr)
+		})
+	}
+}
+
+func TestThermalFrameScale(t *testing.T) {
+	tf := &thermalFrame{values: []float64{100, math.NaN(), 300}}
+	tf.scale(0.5, -20)
+	if tf.min != 30 || tf.max != 130 {
+		t.Errorf("range = [%v, %v], want [30, 130]", tf.min, tf.max)
+	}
+	if !math.IsNaN(tf.values[1]) {
+		t.Errorf("NaN scaled to %v", tf.values[1])
+	}
+}
+
+// checkThermalFrames compares the result of a thermal reader against the wanted
+// frame values or error
+func checkThermalFrames(t *testing.T, frames []*thermalFrame, err error, want [][]float64, width, height int, wantErr string) {
+	t.Helper()
+	if wantErr != "" {
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Fatalf("err = %v, want one containing %q", err, wantErr)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(frames) != len(want) {
+		t.Fatalf("got %d frames, want %d", len(frames), len(want))
+	}
+	for i, tf := range frames {
+		if tf.width != width || tf.height != height {
+			t.Errorf("frame %d is %dx%d, want %dx%d", i, tf.width, tf.height, width, height)
+		}
+		if len(tf.values) != len(want[i]) {
+			t.Fatalf("frame %d has %d values, want %d", i, len(tf.values), len(want[i]))
+		}
+		for j, v := range tf.values {
+			if v != want[i][j] {
+				t.Errorf("frame %d value %d = %v, want %v", i, j, v, want[i][j])
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Timestamp modes for timestamp_mode: how frame capture times are reported
+const (
+	timestampOriginal  = "original"  // recorded capture times
+	timestampWallclock = "wallclock" // the time the frame was shown
+	timestampOffset    = "offset"    // recorded times shifted so the recording starts at timestamp_start
+)
+
+// validateTimestampMode checks timestamp_mode and timestamp_start
+func (c *Config) validateTimestampMode() error {
+	switch c.timestampMode() {
+	case timestampOriginal, timestampWallclock:
+	case timestampOffset:
+		if _, err := c.timestampStart(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid timestamp_mode '%s': must be 'original', 'wallclock' or 'offset'", *c.TimestampMode)
+	}
+	return nil
+}
+
+// timestampMode returns the configured timestamp mode, original by default
+func (c *Config) timestampMode() string {
+	if c.TimestampMode == nil {
+		return timestampOriginal
+	}
+	return *c.TimestampMode
+}
+
+// timestampStart parses timestamp_start, the instant offset mode starts the recording at
+func (c *Config) timestampStart() (time.Time, error) {
+	if c.TimestampStart == nil || *c.TimestampStart == "" {
+		return time.Time{}, fmt.Errorf("timestamp_start is required for timestamp_mode offset")
+	}
+	start, err := time.Parse(time.RFC3339Nano, *c.TimestampStart)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp_start %q: must be RFC3339: %w", *c.TimestampStart, err)
+	}
+	return start, nil
+}
+
+// startTimestamps parses timestamp_start for the current config, once rather
+// than for every frame
+func (s *videoReplayVideo) startTimestamps() {
+	s.stampStart = time.Time{}
+	if s.cfg.timestampMode() == timestampOffset {
+		// Validate has already rejected a bad timestamp_start
+		s.stampStart, _ = s.cfg.timestampStart()
+	}
+}
+
+// stampTime maps a frame's recorded capture time to the time it is reported
+// with, per timestamp_mode
+func (s *videoReplayVideo) stampTime(recorded time.Time) time.Time {
+	switch s.cfg.timestampMode() {
+	case timestampWallclock:
+		return time.Now()
+	case timestampOffset:
+		if s.stampStart.IsZero() || s.recordingStart.IsZero() {
+			return recorded
+		}
+		return s.stampStart.Add(recorded.Sub(s.recordingStart))
+	default:
+		return recorded
+	}
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"gocv.io/x/gocv"
+)
+
+// Fit modes for scaling frames to the configured width/height
+const (
+	fitStretch   = "stretch"   // scale each axis independently
+	fitLetterbox = "letterbox" // keep aspect ratio, pad with black
+	fitCrop      = "crop"      // keep aspect ratio, crop the overflow around the center
+)
+
+// validateOutputSize checks the width, height and fit attributes
+func (c *Config) validateOutputSize() error {
+	if c.Width != nil && *c.Width <= 0 {
+		return fmt.Errorf("width must be positive, got %d", *c.Width)
+	}
+	if c.Height != nil && *c.Height <= 0 {
+		return fmt.Errorf("height must be positive, got %d", *c.Height)
+	}
+	if c.Fit != nil {
+		switch *c.Fit {
+		case fitStretch, fitLetterbox, fitCrop:
+		default:
+			return fmt.Errorf("invalid fit '%s': must be 'stretch', 'letterbox' or 'crop'", *c.Fit)
+		}
+	}
+	return nil
+}
+
+// outputSize returns the size frames are scaled to for a source of the given
+// size. With only one of width/height configured the other follows the
+// source aspect ratio; with neither the source size is kept.
+func (c *Config) outputSize(srcWidth, srcHeight int) (int, int) {
+	switch {
+	case c.Width != nil && c.Height != nil:
+		return *c.Width, *c.Height
+	case c.Width != nil && srcWidth > 0:
+		return *c.Width, max(1, srcHeight**c.Width/srcWidth)
+	case c.Height != nil && srcHeight > 0:
+		return max(1, srcWidth**c.Height/srcHeight), *c.Height
+	default:
+		return srcWidth, srcHeight
+	}
+}
+
+// fitLayout describes where a scaled source image lands in an output frame
+type fitLayout struct {
+	width, height     int // output frame size
+	scaledW, scaledH  int // size the source is scaled to
+	offsetX, offsetY  int // position of the scaled source in the output; negative when cropped
+	stretch, identity bool
+}
+
+// layout computes how a source of the given size maps onto the configured output
+func (c *Config) layout(srcWidth, srcHeight int) fitLayout {
+	width, height := c.outputSize(srcWidth, srcHeight)
+	l := fitLayout{width: width, height: height, scaledW: width, scaledH: height}
+	if width == srcWidth && height == srcHeight {
+		l.identity = true
+		return l
+	}
+
+	fit := fitStretch
+	if c.Fit != nil {
+		fit = *c.Fit
+	}
+	if fit == fitStretch || srcWidth <= 0 || srcHeight <= 0 {
+		l.stretch = true
+		return l
+	}
+
+	// Scale by the limiting (letterbox) or covering (crop) axis
+	scaleX := float64(width) / float64(srcWidth)
+	scaleY := float64(height) / float64(srcHeight)
+	scale := min(scaleX, scaleY)
+	if fit == fitCrop {
+		scale = max(scaleX, scaleY)
+	}
+	l.scaledW = max(1, int(float64(srcWidth)*scale+0.5))
+	l.scaledH = max(1, int(float64(srcHeight)*scale+0.5))
+	l.offsetX = (width - l.scaledW) / 2
+	l.offsetY = (height - l.scaledH) / 2
+	return l
+}
+
+// resizeFrame scales frame to the configured output size, taking ownership of
+// frame. It returns frame unchanged when no resize is needed.
+func (c *Config) resizeFrame(frame gocv.Mat) gocv.Mat {
+	if frame.Empty() {
+		return frame
+	}
+	srcWidth, srcHeight := frame.Cols(), frame.Rows()
+	l := c.layout(srcWidth, srcHeight)
+	if l.identity {
+		return frame
+	}
+	defer frame.Close()
+
+	// Depth values must not be blended between pixels
+	interp := gocv.InterpolationArea
+	if isDepthFrame(frame) {
+		interp = gocv.InterpolationNearestNeighbor
+	} else if l.scaledW > srcWidth || l.scaledH > srcHeight {
+		interp = gocv.InterpolationLinear
+	}
+
+	out := gocv.NewMat()
+	if l.stretch {
+		gocv.Resize(frame, &out, image.Pt(l.width, l.height), 0, 0, interp)
+		return out
+	}
+
+	scaled := gocv.NewMat()
+	defer scaled.Close()
+	gocv.Resize(frame, &scaled, image.Pt(l.scaledW, l.scaledH), 0, 0, interp)
+
+	if l.offsetX >= 0 && l.offsetY >= 0 {
+		// Letterbox: pad around the scaled image
+		gocv.CopyMakeBorder(scaled, &out, l.offsetY, l.height-l.scaledH-l.offsetY,
+			l.offsetX, l.width-l.scaledW-l.offsetX, gocv.BorderConstant, color.RGBA{})
+	} else {
+		// Crop: cut the output window out of the scaled image
+		x, y := -l.offsetX, -l.offsetY
+		region := scaled.Region(image.Rect(x, y, x+l.width, y+l.height))
+		region.CopyTo(&out)
+		region.Close()
+	}
+	return out
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateOutputSize(t *testing.T) {
+	size := func(v int) *int { return &v }
+	fit := func(v string) *string { return &v }
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{name: "unset", cfg: Config{}},
+		{name: "valid", cfg: Config{Width: size(640), Height: size(480), Fit: fit(fitCrop)}},
+		{name: "zero width", cfg: Config{Width: size(0)}, wantErr: "width must be positive"},
+		{name: "negative height", cfg: Config{Height: size(-1)}, wantErr: "height must be positive"},
+		{name: "unknown fit", cfg: Config{Fit: fit("zoom")}, wantErr: "invalid fit 'zoom'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.validateOutputSize()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOutputSize(t *testing.T) {
+	size := func(v int) *int { return &v }
+	tests := []struct {
+		name                  string
+		width, height         *int
+		srcWidth, srcHeight   int
+		wantWidth, wantHeight int
+	}{
+		{name: "source size", srcWidth: 1920, srcHeight: 1080, wantWidth: 1920, wantHeight: 1080},
+		{name: "both set", width: size(640), height: size(640), srcWidth: 1920, srcHeight: 1080, wantWidth: 640, wantHeight: 640},
+		{name: "width follows aspect", width: size(640), srcWidth: 1920, srcHeight: 1080, wantWidth: 640, wantHeight: 360},
+		{name: "height follows aspect", height: size(540), srcWidth: 1920, srcHeight: 1080, wantWidth: 960, wantHeight: 540},
+		{name: "never collapses to zero", width: size(10), srcWidth: 10000, srcHeight: 10, wantWidth: 10, wantHeight: 1},
+		{name: "unknown source", width: size(640), wantWidth: 0, wantHeight: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Width: tt.width, Height: tt.height}
+			w, h := cfg.outputSize(tt.srcWidth, tt.srcHeight)
+			if w != tt.wantWidth || h != tt.wantHeight {
+				t.Errorf("outputSize(%d, %d) = %dx%d, want %dx%d", tt.srcWidth, tt.srcHeight, w, h, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestLayout(t *testing.T) {
+	size := func(v int) *int { return &v }
+	fit := func(v string) *string { return &v }
+	tests := []struct {
+		name                string
+		cfg                 Config
+		srcWidth, srcHeight int
+		want                fitLayout
+	}{
+		{
+			name:     "no resize",
+			cfg:      Config{},
+			srcWidth: 640, srcHeight: 480,
+			want: fitLayout{width: 640, height: 480, scaledW: 640, scaledH: 480, identity: true},
+		},
+		{
+			name:     "configured to the source size",
+			cfg:      Config{Width: size(640), Height: size(480), Fit: fit(fitCrop)},
+			srcWidth: 640, srcHeight: 480,
+			want: fitLayout{width: 640, height: 480, scaledW: 640, scaledH: 480, identity: true},
+		},
+		{
+			name:     "stretch by default",
+			cfg:      Config{Width: size(300), Height: size(300)},
+			srcWidth: 640, srcHeight: 480,
+			want: fitLayout{width: 300, height: 300, scaledW: 300, scaledH: 300, stretch: true},
+		},
+		{
+			name:     "letterbox wide source",
+			cfg:      Config{Width: size(400), Height: size(400), Fit: fit(fitLetterbox)},
+			srcWidth: 800, srcHeight: 400,
+			want: fitLayout{width: 400, height: 400, scaledW: 400, scaledH: 200, offsetY: 100},
+		},
+		{
+			name:     "letterbox tall source",
+			cfg:      Config{Width: size(400), Height: size(200), Fit: fit(fitLetterbox)},
+			srcWidth: 300, srcHeight: 600,
+			want: fitLayout{width: 400, height: 200, scaledW: 100, scaledH: 200, offsetX: 150},
+		},
+		{
+			name:     "crop wide source",
+			cfg:      Config{Width: size(400), Height: size(400), Fit: fit(fitCrop)},
+			srcWidth: 800, srcHeight: 400,
+			want: fitLayout{width: 400, height: 400, scaledW: 800, scaledH: 400, offsetX: -200},
+		},
+		{
+			name:     "crop upscales tall source",
+			cfg:      Config{Width: size(200), Height: size(100), Fit: fit(fitCrop)},
+			srcWidth: 50, srcHeight: 100,
+			want: fitLayout{width: 200, height: 100, scaledW: 200, scaledH: 400, offsetY: -150},
+		},
+		{
+			name:     "unknown source stretches",
+			cfg:      Config{Width: size(400), Height: size(400), Fit: fit(fitLetterbox)},
+			srcWidth: 0, srcHeight: 0,
+			want: fitLayout{width: 400, height: 400, scaledW: 400, scaledH: 400, stretch: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.layout(tt.srcWidth, tt.srcHeight); got != tt.want {
+				t.Errorf("layout(%d, %d) = %+v, want %+v", tt.srcWidth, tt.srcHeight, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.viam.com/rdk/resource"
+)
+
+// defaultTriggerPollMs is how often a trigger dependency is polled when
+// poll_ms isn't configured
+const defaultTriggerPollMs = 100
+
+// TriggerConfig starts an armed replay when a dependency reports ready: when
+// the value at key in its DoCommand response, or in its Readings when no
+// command is set, is above or below a threshold, or truthy without one.
+type TriggerConfig struct {
+	Resource string                 `json:"resource"`          // name of the dependency to poll
+	Command  map[string]interface{} `json:"command,omitempty"` // DoCommand to send; Readings are polled when unset
+	Key      string                 `json:"key"`               // response or reading field to check
+	Above    *float64               `json:"above,omitempty"`   // trigger once the value is above this
+	Below    *float64               `json:"below,omitempty"`   // trigger once the value is below this
+	PollMs   *int                   `json:"poll_ms,omitempty"` // polling interval (default 100)
+}
+
+// validate checks the trigger fields
+func (t *TriggerConfig) validate() error {
+	if t.Resource == "" {
+		return fmt.Errorf("trigger.resource is required")
+	}
+	if t.Key == "" {
+		return fmt.Errorf("trigger.key is required")
+	}
+	if t.Above != nil && t.Below != nil {
+		return fmt.Errorf("trigger can't set both above and below")
+	}
+	if t.PollMs != nil && *t.PollMs <= 0 {
+		return fmt.Errorf("trigger.poll_ms must be positive, got %d", *t.PollMs)
+	}
+	return nil
+}
+
+// pollInterval returns the time between polls of the dependency
+func (t *TriggerConfig) pollInterval() time.Duration {
+	if t.PollMs == nil {
+		return defaultTriggerPollMs * time.Millisecond
+	}
+	return time.Duration(*t.PollMs) * time.Millisecond
+}
+
+// satisfied reports whether a polled value fires the trigger
+func (t *TriggerConfig) satisfied(v interface{}) bool {
+	if t.Above != nil || t.Below != nil {
+		f, ok := numberValue(v)
+		if !ok {
+			return false
+		}
+		if t.Above != nil {
+			return f > *t.Above
+		}
+		return f < *t.Below
+	}
+	if f, ok := numberValue(v); ok {
+		return f != 0
+	}
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		return v != ""
+	default:
+		return v != nil
+	}
+}
+
+// dependencies returns the resources the config depends on
+func (c *Config) dependencies() []string {
+	if c.Trigger == nil {
+		return nil
+	}
+	return []string{c.Trigger.Resource}
+}
+
+// resolveTrigger finds the configured trigger dependency
+func (s *videoReplayVideo) resolveTrigger(deps resource.Dependencies) error {
+	s.triggerDep = nil
+	if s.cfg.Trigger == nil {
+		return nil
+	}
+	for name, res := range deps {
+		if name.ShortName() == s.cfg.Trigger.Resource || name.Name == s.cfg.Trigger.Resource {
+			s.triggerDep = res
+			return nil
+		}
+	}
+	return fmt.Errorf("trigger resource %q not found in dependencies", s.cfg.Trigger.Resource)
+}
+
+// armTimeline holds playback at the first frame until triggerTimeline: update
+// loops started from now on wait for the trigger, and a sync group's clock is
+// paused at its start. With a trigger dependency, it is polled meanwhile.
+// Callers must hold lifecycleMu, or be constructing the camera.
+func (s *videoReplayVideo) armTimeline() {
+	s.stopTriggerWatch()
+
+	s.triggerMu.Lock()
+	s.started = make(chan struct{})
+	s.triggeredAt = time.Time{}
+	s.triggerMu.Unlock()
+
+	if s.clock != nil {
+		s.clock.pause()
+		s.clock.seek(0)
+	}
+	if s.triggerDep != nil {
+		ctx, cancel := context.WithCancel(s.mainCtx)
+		s.triggerMu.Lock()
+		s.triggerCancel = cancel
+		s.triggerMu.Unlock()
+		s.triggerWG.Add(1)
+		go func() {
+			defer s.triggerWG.Done()
+			s.watchTrigger(ctx)
+		}()
+	}
+	s.logger.Infof("[armTimeline] Camera %q armed, holding at the first frame", s.name)
+}
+
+// triggerTimeline starts the held timeline; it reports false if it wasn't armed
+func (s *videoReplayVideo) triggerTimeline(by string) bool {
+	s.triggerMu.Lock()
+	defer s.triggerMu.Unlock()
+	if s.started == nil || !s.triggeredAt.IsZero() {
+		return false
+	}
+	s.triggeredAt = time.Now()
+	close(s.started)
+	if s.clock != nil {
+		s.clock.play()
+	}
+	s.logger.Infof("[triggerTimeline] Camera %q triggered by %s", s.name, by)
+	return true
+}
+
+// disarm drops any hold, so loops started from now on play right away
+func (s *videoReplayVideo) disarm() {
+	s.stopTriggerWatch()
+	s.triggerMu.Lock()
+	defer s.triggerMu.Unlock()
+	s.started = nil
+	s.triggeredAt = time.Time{}
+}
+
+// armed reports whether playback is held for a trigger
+func (s *videoReplayVideo) armed() bool {
+	s.triggerMu.Lock()
+	defer s.triggerMu.Unlock()
+	return s.started != nil && s.triggeredAt.IsZero()
+}
+
+// awaitTrigger blocks an update loop until the timeline is triggered. It
+// returns false if ctx ends first.
+func (s *videoReplayVideo) awaitTrigger(ctx context.Context) bool {
+	s.triggerMu.Lock()
+	started := s.started
+	s.triggerMu.Unlock()
+	if started == nil {
+		return true
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-started:
+		return true
+	}
+}
+
+// stopTriggerWatch stops polling the trigger dependency. Callers must hold
+// lifecycleMu, or be constructing the camera.
+func (s *videoReplayVideo) stopTriggerWatch() {
+	s.triggerMu.Lock()
+	cancel := s.triggerCancel
+	s.triggerCancel = nil
+	s.triggerMu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	s.triggerWG.Wait()
+}
+
+// watchTrigger polls the trigger dependency until it fires or ctx ends
+func (s *videoReplayVideo) watchTrigger(ctx context.Context) {
+	trigger := s.cfg.Trigger
+	s.logger.Infof("[watchTrigger] Polling %q every %v for camera %q", trigger.Resource, trigger.pollInterval(), s.name)
+	ticker := time.NewTicker(trigger.pollInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.armed() {
+				return
+			}
+			v, err := s.pollTrigger(ctx)
+			if err != nil {
+				s.logger.Debugf("[watchTrigger] Failed to poll %q: %v", trigger.Resource, err)
+				continue
+			}
+			if trigger.satisfied(v) {
+				s.triggerTimeline(fmt.Sprintf("%q (%s=%v)", trigger.Resource, trigger.Key, v))
+				return
+			}
+		}
+	}
+}
+
+// pollTrigger returns the trigger key's current value from the dependency
+func (s *videoReplayVideo) pollTrigger(ctx context.Context) (interface{}, error) {
+	trigger := s.cfg.Trigger
+	var resp map[string]interface{}
+	var err error
+	if trigger.Command != nil {
+		resp, err = s.triggerDep.DoCommand(ctx, trigger.Command)
+	} else {
+		sensor, ok := s.triggerDep.(resource.Sensor)
+		if !ok {
+			return nil, fmt.Errorf("%q has no readings; set trigger.command", trigger.Resource)
+		}
+		resp, err = sensor.Readings(ctx, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp[trigger.Key], nil
+}
+
+// triggerCommand handles the "arm" and "trigger" DoCommands
+func (s *videoReplayVideo) triggerCommand(name string) (map[string]interface{}, error) {
+	switch name {
+	case "arm":
+		s.lifecycleMu.Lock()
+		defer s.lifecycleMu.Unlock()
+		if s.mainCtx.Err() != nil {
+			return nil, fmt.Errorf("camera %q is closed", s.name)
+		}
+		s.armTimeline()
+		if s.clock == nil {
+			if err := s.restartPlayback(); err != nil {
+				return nil, fmt.Errorf("arm: %w", err)
+			}
+		}
+	case "trigger":
+		if !s.triggerTimeline("DoCommand") {
+			return nil, fmt.Errorf("camera %q is not armed", s.name)
+		}
+	}
+	return s.triggerStatus(), nil
+}
+
+// triggerStatus reports whether playback is held and when it was triggered
+func (s *videoReplayVideo) triggerStatus() map[string]interface{} {
+	s.triggerMu.Lock()
+	defer s.triggerMu.Unlock()
+	status := map[string]interface{}{
+		"armed": s.started != nil && s.triggeredAt.IsZero(),
+	}
+	if !s.triggeredAt.IsZero() {
+		status["triggered_at"] = s.triggeredAt.UTC().Format(time.RFC3339Nano)
+	}
+	return status
+}
+
+// startPaused reports whether playback waits for a trigger after loading
+func (c *Config) startPaused() bool {
+	return c.StartPaused != nil && *c.StartPaused
+}
+
+// restartPlayback goes back to the source's first frame and restarts the
+// update loop, which waits for the trigger when armed. Callers must hold
+// lifecycleMu.
+func (s *videoReplayVideo) restartPlayback() error {
+	switch {
+	case s.thermal != nil:
+		s.stopLoop()
+		return s.initThermalReplay()
+	case len(s.videoFiles) > 0:
+		return s.openAndStartLoop(s.videoFiles...)
+	case s.datasetReplay != nil:
+		s.stopLoop()
+		s.datasetReplay.seekIndex(0)
+		return s.startDatasetLoop()
+	default:
+		return fmt.Errorf("no source loaded")
+	}
+}
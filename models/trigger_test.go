@@ -0,0 +1,83 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTriggerConfigSatisfied(t *testing.T) {
+	threshold := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name  string
+		above *float64
+		below *float64
+		value interface{}
+		want  bool
+	}{
+		{name: "above threshold", above: threshold(50), value: 50.5, want: true},
+		{name: "at the above threshold", above: threshold(50), value: 50.0, want: false},
+		{name: "above threshold int", above: threshold(50), value: 51, want: true},
+		{name: "below threshold", below: threshold(-1), value: -3.0, want: true},
+		{name: "not below threshold", below: threshold(-1), value: 0.0, want: false},
+		{name: "below threshold uint", below: threshold(10), value: uint8(9), want: true},
+		{name: "threshold on a string", above: threshold(0), value: "100", want: false},
+		{name: "threshold on nothing", above: threshold(0), value: nil, want: false},
+		{name: "true", value: true, want: true},
+		{name: "false", value: false, want: false},
+		{name: "nonzero number", value: 0.1, want: true},
+		{name: "zero number", value: 0.0, want: false},
+		{name: "zero int", value: int32(0), want: false},
+		{name: "nonempty string", value: "ready", want: true},
+		{name: "empty string", value: "", want: false},
+		{name: "object", value: map[string]interface{}{}, want: true},
+		{name: "missing", value: nil, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trigger := &TriggerConfig{Above: tt.above, Below: tt.below}
+			if got := trigger.satisfied(tt.value); got != tt.want {
+				t.Errorf("satisfied(%#v) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTriggerConfigValidate(t *testing.T) {
+	threshold, poll := 1.0, 0
+	tests := []struct {
+		name    string
+		trigger TriggerConfig
+		wantErr string
+	}{
+		{name: "valid", trigger: TriggerConfig{Resource: "door", Key: "open"}},
+		{name: "no resource", trigger: TriggerConfig{Key: "open"}, wantErr: "trigger.resource is required"},
+		{name: "no key", trigger: TriggerConfig{Resource: "door"}, wantErr: "trigger.key is required"},
+		{name: "both thresholds", trigger: TriggerConfig{Resource: "door", Key: "open", Above: &threshold, Below: &threshold}, wantErr: "both above and below"},
+		{name: "zero poll", trigger: TriggerConfig{Resource: "door", Key: "open", PollMs: &poll}, wantErr: "trigger.poll_ms must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.trigger.validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTriggerConfigPollInterval(t *testing.T) {
+	poll := 250
+	if got := (&TriggerConfig{}).pollInterval(); got != defaultTriggerPollMs*time.Millisecond {
+		t.Errorf("default pollInterval = %v", got)
+	}
+	if got := (&TriggerConfig{PollMs: &poll}).pollInterval(); got != 250*time.Millisecond {
+		t.Errorf("pollInterval = %v, want 250ms", got)
+	}
+}